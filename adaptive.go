@@ -0,0 +1,149 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Learns the latency distribution of each OCSP responder (identified by the
+// host of its URL), and derives per-query timeouts from it.  Once at least
+// MinSamples queries to a responder have been observed, queries to it time out
+// after the Percentile latency plus Margin, bounded by Minimum and the maximum
+// query timeout ([PhaseTimeouts].Query, or [QueryTimeout]).  This improves
+// throughput when scanning fast responders, while still catching slow outliers.
+//
+// Queries which time out or fail without a response count as taking as long as
+// the timeout allowed, so if a responder slows down, the timeout grows back.
+//
+// The zero value is ready to use and provides sensible defaults.  An AdaptiveTimeout
+// is safe for concurrent use, and can be shared by multiple [Config]s.
+type AdaptiveTimeout struct {
+	// The latency percentile, between 0 and 1, on which the timeout is based.  If zero, 0.99 is used.
+	Percentile float64
+
+	// The amount of time added to the percentile latency.  If zero, 1 second is used.
+	Margin time.Duration
+
+	// The smallest timeout that will be used.  If zero, 2 seconds is used.
+	Minimum time.Duration
+
+	// The number of samples required before the timeout adapts.  If zero, 20 is used.
+	MinSamples int
+
+	// The number of most recent samples retained for each responder.  If zero, 1000 is used.
+	Window int
+
+	samples responderSamples[time.Duration]
+}
+
+func (at *AdaptiveTimeout) percentile() float64 {
+	if at.Percentile > 0 && at.Percentile <= 1 {
+		return at.Percentile
+	} else {
+		return 0.99
+	}
+}
+
+func (at *AdaptiveTimeout) margin() time.Duration {
+	if at.Margin != 0 {
+		return at.Margin
+	} else {
+		return 1 * time.Second
+	}
+}
+
+func (at *AdaptiveTimeout) minimum() time.Duration {
+	if at.Minimum != 0 {
+		return at.Minimum
+	} else {
+		return 2 * time.Second
+	}
+}
+
+func (at *AdaptiveTimeout) minSamples() int {
+	if at.MinSamples != 0 {
+		return at.MinSamples
+	} else {
+		return 20
+	}
+}
+
+func (at *AdaptiveTimeout) window() int {
+	if at.Window != 0 {
+		return at.Window
+	} else {
+		return 1000
+	}
+}
+
+func responderKey(serverURL string) string {
+	if parsedURL, err := url.Parse(serverURL); err == nil {
+		return parsedURL.Host
+	} else {
+		return serverURL
+	}
+}
+
+// Record that a query to the responder at serverURL took the given amount of time.
+// [Query] calls this automatically when [Config].AdaptiveTimeout is set.
+func (at *AdaptiveTimeout) Observe(serverURL string, latency time.Duration) {
+	at.samples.add(responderKey(serverURL), latency, at.window())
+}
+
+// Return the timeout to use for a query to the responder at serverURL, at most
+// [QueryTimeout].  Returns QueryTimeout if fewer than MinSamples queries to the
+// responder have been observed.
+func (at *AdaptiveTimeout) Timeout(serverURL string) time.Duration {
+	return at.timeout(serverURL, QueryTimeout)
+}
+
+// Like Timeout, but bounded by maximum instead of QueryTimeout
+func (at *AdaptiveTimeout) timeout(serverURL string, maximum time.Duration) time.Duration {
+	durations := at.samples.get(responderKey(serverURL))
+	if len(durations) < at.minSamples() {
+		return maximum
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	index := int(at.percentile()*float64(len(durations))+0.5) - 1
+	if index < 0 {
+		index = 0
+	} else if index >= len(durations) {
+		index = len(durations) - 1
+	}
+
+	timeout := durations[index] + at.margin()
+	if timeout < at.minimum() {
+		timeout = at.minimum()
+	}
+	if timeout > maximum {
+		timeout = maximum
+	}
+	return timeout
+}
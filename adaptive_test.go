@@ -0,0 +1,106 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTimeout(t *testing.T) {
+	const serverURL = "http://ocsp.example.com/path"
+	at := &AdaptiveTimeout{Percentile: 0.5, Margin: 100 * time.Millisecond, Minimum: time.Millisecond, MinSamples: 3, Window: 3}
+
+	at.Observe(serverURL, time.Second)
+	at.Observe(serverURL, time.Second)
+	if timeout := at.Timeout(serverURL); timeout != QueryTimeout {
+		t.Errorf("timeout with too few samples is %s; want %s", timeout, QueryTimeout)
+	}
+
+	at.Observe(serverURL, 2*time.Second)
+	if timeout, want := at.Timeout(serverURL), 1100*time.Millisecond; timeout != want {
+		t.Errorf("timeout is %s; want %s", timeout, want)
+	}
+
+	// Samples are kept per responder host, and old samples fall out of the window
+	if timeout := at.Timeout("http://other.example.com"); timeout != QueryTimeout {
+		t.Errorf("timeout for another responder is %s; want %s", timeout, QueryTimeout)
+	}
+	for i := 0; i < 3; i++ {
+		at.Observe("http://ocsp.example.com/other", time.Hour)
+	}
+	if timeout := at.Timeout(serverURL); timeout != QueryTimeout {
+		t.Errorf("timeout is %s; want it capped at %s", timeout, QueryTimeout)
+	}
+}
+
+func TestAdaptiveTimeoutGrowsAfterFailure(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := &AdaptiveTimeout{Percentile: 1, Margin: 20 * time.Millisecond, Minimum: 50 * time.Millisecond, MinSamples: 3, Window: 3}
+	for i := 0; i < 3; i++ {
+		at.Observe(responder.URL, time.Millisecond)
+	}
+	initial := at.Timeout(responder.URL)
+
+	// The responder slows down, so the query times out, but the timeout is
+	// recorded as a sample so the next query is allowed longer
+	responder.SetLatency(time.Second)
+	config := &Config{AdaptiveTimeout: at}
+	eval := Evaluate(context.Background(), cert.Raw, responder.CA.RawSubject, responder.CA.RawSubjectPublicKeyInfo, config)
+	if eval.Err == nil {
+		t.Fatal("evaluation succeeded despite the timeout")
+	}
+	if timeout := at.Timeout(responder.URL); timeout <= initial {
+		t.Errorf("timeout after a failure is %s; want more than %s", timeout, initial)
+	}
+}
+
+func TestAdaptiveTimeoutConcurrent(t *testing.T) {
+	const serverURL = "http://ocsp.example.com"
+	at := &AdaptiveTimeout{MinSamples: 10, Window: 50}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				at.Observe(serverURL, time.Duration(i*j)*time.Millisecond)
+				at.Timeout(serverURL)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if timeout := at.Timeout(serverURL); timeout <= 0 || timeout > QueryTimeout {
+		t.Errorf("timeout is %s; want between 0 and %s", timeout, QueryTimeout)
+	}
+}
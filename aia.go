@@ -0,0 +1,272 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNoIssuerURL is returned when the certificate does not contain an HTTP caIssuers URL
+var ErrNoIssuerURL = errors.New("Certificate does not contain an HTTP caIssuers URL")
+
+// The maximum size of a certificate bundle downloaded by [FetchIssuer]
+const maxIssuerSize = 1 << 20
+
+var oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// Caches the certificates downloaded by [FetchIssuer], keyed by URL.  The zero
+// value is an empty cache ready to use.  An IssuerCache is safe for concurrent use.
+type IssuerCache struct {
+	// If non-zero, the maximum number of URLs whose certificates are cached.
+	// When the cache is full, the least recently used URL is discarded.
+	MaxEntries int
+
+	certs cacheMap[string, []*x509.Certificate]
+}
+
+func (cache *IssuerCache) get(issuerURL string) ([]*x509.Certificate, bool) {
+	return cache.certs.get(issuerURL)
+}
+
+func (cache *IssuerCache) put(issuerURL string, certs []*x509.Certificate) {
+	var size int64
+	for _, cert := range certs {
+		size += int64(len(cert.Raw))
+	}
+	cache.certs.put(issuerURL, certs, size, time.Time{}, cache.MaxEntries)
+}
+
+// Return statistics about the cache, e.g. for exporting as metrics
+func (cache *IssuerCache) Stats() CacheStats {
+	return cache.certs.statistics()
+}
+
+// Given a certificate, download its issuer from the certificate's Authority
+// Information Access caIssuers URLs.  The downloaded data may be a DER-encoded
+// certificate, one or more PEM-encoded certificates, or a "certs-only" PKCS#7
+// bundle.  The issuer is the first downloaded certificate whose subject matches
+// the certificate's issuer, whose subject key identifier matches the certificate's
+// authority key identifier (if both are present), and whose key verifies the
+// certificate's signature.
+//
+// Since the returned certificate's key signed cert, it should be passed as
+// the issuerCert to other functions in this package only if cert is not a
+// precertificate signed by a dedicated precertificate signing CA.
+//
+// The timeout for each download is defined by [Config].PhaseTimeouts.AIAFetch.
+// If [Config].IssuerCache is non-nil, downloads are cached by URL.
+//
+// If config is nil, a zero-value [Config] is used, which provides
+// sensible defaults.
+//
+// Returns [ErrNoIssuerURL] if the certificate lacks an "http://" or "https://"
+// caIssuers URL, or an error if no matching issuer could be downloaded.
+func FetchIssuer(ctx context.Context, cert *x509.Certificate, config *Config) (*x509.Certificate, error) {
+	var lastErr error = ErrNoIssuerURL
+	for _, issuerURL := range cert.IssuingCertificateURL {
+		if !strings.HasPrefix(issuerURL, "http://") && !strings.HasPrefix(issuerURL, "https://") {
+			continue
+		}
+		candidates, err := fetchIssuerCerts(ctx, issuerURL, config)
+		if err != nil {
+			lastErr = withCode(ErrorCodeIssuerFetch, fmt.Errorf("error fetching issuer from %s: %w", issuerURL, err))
+			continue
+		}
+		for _, candidate := range candidates {
+			if isIssuerOf(candidate, cert) {
+				return candidate, nil
+			}
+		}
+		lastErr = withCode(ErrorCodeIssuerFetch, fmt.Errorf("%s does not contain the certificate's issuer", issuerURL))
+	}
+	return nil, lastErr
+}
+
+func isIssuerOf(issuerCert *x509.Certificate, cert *x509.Certificate) bool {
+	if !bytes.Equal(issuerCert.RawSubject, cert.RawIssuer) {
+		return false
+	}
+	if len(cert.AuthorityKeyId) > 0 && len(issuerCert.SubjectKeyId) > 0 && !bytes.Equal(cert.AuthorityKeyId, issuerCert.SubjectKeyId) {
+		return false
+	}
+	return issuerCert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+func fetchIssuerCerts(ctx context.Context, issuerURL string, config *Config) ([]*x509.Certificate, error) {
+	cache := config.issuerCache()
+	if cache != nil {
+		if certs, ok := cache.get(issuerURL); ok {
+			return certs, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.aiaFetchTimeout())
+	defer cancel()
+
+	httpRequest, err := http.NewRequestWithContext(ctx, "GET", issuerURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("User-Agent", config.userAgent())
+
+	httpResponse, err := config.httpClient().Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxIssuerSize+1))
+	httpResponse.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if httpResponse.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP error: %s", httpResponse.Status)
+	}
+	if len(body) > maxIssuerSize {
+		return nil, fmt.Errorf("response is larger than %d bytes", maxIssuerSize)
+	}
+
+	certs, err := ParseCertificates(body)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		cache.put(issuerURL, certs)
+	}
+	return certs, nil
+}
+
+// Parse one or more certificates, which may be encoded as a single DER certificate,
+// one or more PEM "CERTIFICATE" blocks, or a "certs-only" PKCS#7 SignedData structure
+// (either DER-encoded or in a PEM "PKCS7" block).  Text outside of PEM blocks,
+// such as that output by "openssl s_client -showcerts", and PEM blocks of other
+// types are ignored.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		return parsePEMCertificates(data)
+	}
+	if cert, err := x509.ParseCertificate(data); err == nil {
+		return []*x509.Certificate{cert}, nil
+	}
+	return parsePKCS7Certificates(data)
+}
+
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		case "PKCS7":
+			pkcs7Certs, err := parsePKCS7Certificates(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, pkcs7Certs...)
+		}
+		data = rest
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in PEM data")
+	}
+	return certs, nil
+}
+
+func parsePKCS7Certificates(data []byte) ([]*x509.Certificate, error) {
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+	if _, err := asn1.Unmarshal(data, &contentInfo); err != nil {
+		return nil, fmt.Errorf("data is neither a certificate nor PKCS#7: %w", err)
+	}
+	if !contentInfo.ContentType.Equal(oidPKCS7SignedData) {
+		return nil, fmt.Errorf("PKCS#7 content type %s is not SignedData", contentInfo.ContentType)
+	}
+
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+		CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+		SignerInfos      asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("error parsing PKCS#7 SignedData: %w", err)
+	}
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("PKCS#7 SignedData contains no certificates")
+	}
+	return certs, nil
+}
+
+// Like [CheckCert], but download the issuer using [FetchIssuer] instead of
+// requiring it to be provided.  cert must not be a precertificate signed by a
+// dedicated precertificate signing CA.
+func CheckLeaf(ctx context.Context, cert *x509.Certificate, config *Config) (revoked bool, info RevocationInfo, err error) {
+	issuerCert, err := FetchIssuer(ctx, cert, config)
+	if err != nil {
+		return
+	}
+	return CheckCert(ctx, cert, issuerCert, config)
+}
+
+// Like [Evaluate], but download the issuer using [FetchIssuer] instead of
+// requiring it to be provided.  certData must not be a precertificate signed by
+// a dedicated precertificate signing CA.
+func EvaluateLeaf(ctx context.Context, certData []byte, config *Config) Evaluation {
+	cert, err := x509.ParseCertificate(certData)
+	if err != nil {
+		return Evaluation{Vantage: config.vantage(), Err: withCode(ErrorCodeCertificateInvalid, fmt.Errorf("unable to parse certificate: %w", err))}
+	}
+	issuerCert, err := FetchIssuer(ctx, cert, config)
+	if err != nil {
+		return Evaluation{Vantage: config.vantage(), Err: err}
+	}
+	return Evaluate(ctx, certData, issuerCert.RawSubject, issuerCert.RawSubjectPublicKeyInfo, config)
+}
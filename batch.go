@@ -0,0 +1,230 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A certificate to be evaluated by [EvaluateBatch].  CertData, IssuerSubject,
+// and IssuerPubkey have the same meaning as the arguments to [Evaluate].
+type BatchItem struct {
+	CertData      []byte
+	IssuerSubject []byte
+	IssuerPubkey  []byte
+
+	// An arbitrary value for the caller's use, which is passed back to the callback
+	Tag interface{}
+}
+
+// Contains options for [EvaluateBatch].  The zero value provides sensible defaults.
+type BatchOptions struct {
+	// The maximum number of evaluations to perform concurrently.  If zero, 16 is used.
+	Concurrency int
+
+	// The maximum number of queries per second sent to each responder host.
+	// If zero, queries are not rate limited.
+	ResponderRateLimit float64
+
+	// If non-nil, every evaluation is added to this summary, whose duration is
+	// measured from the start of the batch
+	Summary *ScanSummary
+}
+
+func (options *BatchOptions) concurrency() int {
+	if options != nil && options.Concurrency > 0 {
+		return options.Concurrency
+	} else {
+		return 16
+	}
+}
+
+func (options *BatchOptions) responderRateLimit() float64 {
+	if options != nil {
+		return options.ResponderRateLimit
+	} else {
+		return 0
+	}
+}
+
+func (options *BatchOptions) summary() *ScanSummary {
+	if options != nil {
+		return options.Summary
+	} else {
+		return nil
+	}
+}
+
+// Evaluate every certificate received from items, and invoke callback with
+// each result as it completes.  Results are not necessarily delivered in the
+// order that items are received.  Invocations of callback are serialized, so
+// callback need not be safe for concurrent use.
+//
+// EvaluateBatch returns once items has been closed and every evaluation has
+// completed.  If ctx is cancelled, the remaining items are still consumed, and
+// their evaluations fail promptly with ctx's error.
+//
+// If config.HTTPClient is nil, EvaluateBatch uses an HTTP client which keeps
+// enough idle connections open to reuse them across concurrent queries to the
+// same responder, unless [http.DefaultTransport] is not an [*http.Transport].
+//
+// If options is nil, a zero-value [BatchOptions] is used, and if config is nil,
+// a zero-value [Config] is used, both of which provide sensible defaults.
+func EvaluateBatch(ctx context.Context, items <-chan BatchItem, options *BatchOptions, config *Config, callback func(BatchItem, Evaluation)) {
+	batchConfig := new(Config)
+	if config != nil {
+		*batchConfig = *config
+	}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok && batchConfig.HTTPClient == nil {
+		// If http.DefaultTransport has been replaced, e.g. by an instrumentation
+		// wrapper, the shared client is used instead
+		transport := defaultTransport.Clone()
+		transport.MaxIdleConnsPerHost = options.concurrency()
+		batchConfig.HTTPClient = &http.Client{Transport: transport}
+		defer transport.CloseIdleConnections()
+	}
+	if batchConfig.ResponderIPOverride != nil {
+		// Share one pinned client across the batch; if it can't be created,
+		// each query fails with the error instead
+		pinned, err := pinnedClient(batchConfig.httpClient(), batchConfig.ResponderIPOverride)
+		if err == nil {
+			batchConfig.pinnedClient = pinned
+			defer pinned.CloseIdleConnections()
+		}
+	}
+	if rate := options.responderRateLimit(); rate > 0 {
+		batchConfig.rateLimiter = &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+	}
+
+	summary := options.summary()
+	if summary != nil {
+		summary.begin()
+	}
+
+	var (
+		wg         sync.WaitGroup
+		callbackMu sync.Mutex
+	)
+	for i := 0; i < options.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				eval := Evaluate(ctx, item.CertData, item.IssuerSubject, item.IssuerPubkey, batchConfig)
+				if summary != nil {
+					summary.Add(eval)
+				}
+				callbackMu.Lock()
+				callback(item, eval)
+				callbackMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Spaces out queries to each responder host so that they are sent at most
+// once per interval
+type rateLimiter struct {
+	interval time.Duration
+
+	mu         sync.Mutex
+	responders map[string]*responderSlots
+}
+
+// The schedule of queries to one responder host
+type responderSlots struct {
+	next     time.Time      // The earliest time at which the next query may be sent
+	canceled map[int64]bool // Reserved times (in Unix nanoseconds) whose queries were canceled
+}
+
+func (limiter *rateLimiter) wait(ctx context.Context, serverURL string) error {
+	key := responderKey(serverURL)
+
+	limiter.mu.Lock()
+	if limiter.responders == nil {
+		limiter.responders = make(map[string]*responderSlots)
+	}
+	slots := limiter.responders[key]
+	if slots == nil {
+		slots = new(responderSlots)
+		limiter.responders[key] = slots
+	}
+	now := time.Now()
+	slot := slots.next
+	if slot.Before(now) {
+		slot = now
+	}
+	slots.next = slot.Add(limiter.interval)
+	limiter.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		limiter.release(key, slot)
+		return ctx.Err()
+	}
+}
+
+// Give back a slot reserved by wait whose query won't be sent.  Since later
+// queries may already be waiting for later slots, canceled slots are given
+// back only once every slot after them has also been canceled.
+func (limiter *rateLimiter) release(key string, slot time.Time) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	slots := limiter.responders[key]
+	if slots.canceled == nil {
+		slots.canceled = make(map[int64]bool)
+	}
+	slots.canceled[slot.UnixNano()] = true
+	for {
+		last := slots.next.Add(-limiter.interval).UnixNano()
+		if !slots.canceled[last] {
+			break
+		}
+		delete(slots.canceled, last)
+		slots.next = slots.next.Add(-limiter.interval)
+	}
+
+	// Slots which have passed can no longer be given back
+	now := time.Now().UnixNano()
+	for canceled := range slots.canceled {
+		if canceled < now {
+			delete(slots.canceled, canceled)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"software.sslmate.com/src/ocsputil/ocsptest"
+)
+
+func TestEvaluateBatch(t *testing.T) {
+	responder := newTestResponder(t)
+	const count = 20
+	items := make(chan BatchItem, count)
+	for i := 0; i < count; i++ {
+		cert, _, err := responder.IssueCertificate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 1 {
+			responder.SetStatus(cert.SerialNumber, ocsptest.CertStatus{Status: ocsp.Revoked})
+		}
+		items <- BatchItem{
+			CertData:      cert.Raw,
+			IssuerSubject: responder.CA.RawSubject,
+			IssuerPubkey:  responder.CA.RawSubjectPublicKeyInfo,
+			Tag:           i,
+		}
+	}
+	close(items)
+
+	summary := new(ScanSummary)
+	seen := make(map[int]bool)
+	EvaluateBatch(context.Background(), items, &BatchOptions{Concurrency: 4, Summary: summary}, nil, func(item BatchItem, eval Evaluation) {
+		i := item.Tag.(int)
+		if seen[i] {
+			t.Errorf("item %d delivered twice", i)
+		}
+		seen[i] = true
+		if eval.Err != nil {
+			t.Errorf("item %d: %s", i, eval.Err)
+			return
+		}
+		if revoked := eval.Response.Revoked(); revoked != (i%2 == 1) {
+			t.Errorf("item %d: revoked = %v", i, revoked)
+		}
+	})
+	if len(seen) != count {
+		t.Errorf("callback invoked for %d items; want %d", len(seen), count)
+	}
+
+	report := summary.Report()
+	if report.Evaluations != count {
+		t.Errorf("summary has %d evaluations; want %d", report.Evaluations, count)
+	}
+	if report.ByStatus["good"] != count/2 || report.ByStatus["revoked"] != count/2 {
+		t.Errorf("summary has statuses %v; want %d good and %d revoked", report.ByStatus, count/2, count/2)
+	}
+	if requests := responder.Requests(); requests != count {
+		t.Errorf("responder received %d requests; want %d", requests, count)
+	}
+}
+
+func TestEvaluateBatchRateLimit(t *testing.T) {
+	responder := newTestResponder(t)
+	const count = 5
+	items := make(chan BatchItem, count)
+	for i := 0; i < count; i++ {
+		cert, _, err := responder.IssueCertificate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		items <- BatchItem{CertData: cert.Raw, IssuerSubject: responder.CA.RawSubject, IssuerPubkey: responder.CA.RawSubjectPublicKeyInfo}
+	}
+	close(items)
+
+	start := time.Now()
+	EvaluateBatch(context.Background(), items, &BatchOptions{Concurrency: count, ResponderRateLimit: 20}, nil, func(item BatchItem, eval Evaluation) {
+		if eval.Err != nil {
+			t.Error(eval.Err)
+		}
+	})
+	// The first query is sent immediately, and the others 50ms apart
+	if elapsed, want := time.Since(start), (count-1)*50*time.Millisecond; elapsed < want {
+		t.Errorf("batch took %s; want at least %s", elapsed, want)
+	}
+}
+
+func TestEvaluateBatchCanceled(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make(chan BatchItem, 1)
+	items <- BatchItem{CertData: cert.Raw, IssuerSubject: responder.CA.RawSubject, IssuerPubkey: responder.CA.RawSubjectPublicKeyInfo}
+	close(items)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var results int
+	EvaluateBatch(ctx, items, nil, nil, func(item BatchItem, eval Evaluation) {
+		results++
+		if eval.Err == nil {
+			t.Errorf("evaluation succeeded despite canceled context")
+		}
+	})
+	if results != 1 {
+		t.Errorf("callback invoked %d times; want 1", results)
+	}
+}
+
+func TestRateLimiterRelease(t *testing.T) {
+	const serverURL = "http://ocsp.example.com"
+	limiter := &rateLimiter{interval: time.Hour}
+
+	// The first query takes the current slot
+	if err := limiter.wait(context.Background(), serverURL); err != nil {
+		t.Fatal(err)
+	}
+
+	// Queries which give up waiting release their slots, so that a later query
+	// doesn't have to wait behind them
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := limiter.wait(ctx, serverURL)
+		cancel()
+		if err == nil {
+			t.Fatal("wait succeeded despite the rate limit")
+		}
+	}
+
+	limiter.mu.Lock()
+	slots := limiter.responders[responderKey(serverURL)]
+	next, canceled := slots.next, len(slots.canceled)
+	limiter.mu.Unlock()
+	if wait := time.Until(next); wait > time.Hour {
+		t.Errorf("next slot is %s away; want at most an hour", wait)
+	}
+	if canceled != 0 {
+		t.Errorf("%d canceled slots remain; want 0", canceled)
+	}
+}
+
+// A wrapper around a RoundTripper, like those installed by instrumentation libraries
+type wrappedTransport struct {
+	http.RoundTripper
+}
+
+func TestEvaluateBatchWrappedDefaultTransport(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make(chan BatchItem, 1)
+	items <- BatchItem{CertData: cert.Raw, IssuerSubject: responder.CA.RawSubject, IssuerPubkey: responder.CA.RawSubjectPublicKeyInfo}
+	close(items)
+
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = wrappedTransport{defaultTransport}
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	EvaluateBatch(context.Background(), items, nil, nil, func(item BatchItem, eval Evaluation) {
+		if eval.Err != nil {
+			t.Error(eval.Err)
+		}
+	})
+}
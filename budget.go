@@ -0,0 +1,121 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Accumulates the [Timings] of many queries, to attribute the time spent by a
+// scan to each phase of the HTTP requests.  This shows whether slowness is
+// caused by the network (DNS, connecting, TLS, and transferring the body) or by
+// the responder (the time to first byte, which is dominated by server processing).
+//
+// Observe can be used as [Config].OnQueryDone, or called from it.
+//
+// The zero value is ready to use.  A TimeBudget is safe for concurrent use.
+type TimeBudget struct {
+	mu      sync.Mutex
+	queries int
+	total   Timings
+}
+
+// The time spent in one phase of the queries in a [TimeBudgetReport]
+type PhaseTime struct {
+	Phase    string        `json:"phase"` // "dns", "connect", "tls_handshake", "first_byte", or "body_read"
+	Time     time.Duration `json:"time"`
+	Fraction float64       `json:"fraction"` // The fraction of the report's Total, between 0 and 1
+}
+
+// A summary of a [TimeBudget], suitable for serializing as JSON
+type TimeBudgetReport struct {
+	Queries int           `json:"queries"`
+	Total   time.Duration `json:"total"` // The sum of the phases of every query
+	Phases  []PhaseTime   `json:"phases"`
+}
+
+// Add the timings of a query to the budget
+func (budget *TimeBudget) Observe(info QueryInfo) {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	budget.queries++
+	budget.total.DNS += info.Timings.DNS
+	budget.total.Connect += info.Timings.Connect
+	budget.total.TLSHandshake += info.Timings.TLSHandshake
+	budget.total.FirstByte += info.Timings.FirstByte
+	budget.total.BodyRead += info.Timings.BodyRead
+}
+
+// Return a report of the time spent in each phase by the queries observed so far
+func (budget *TimeBudget) Report() TimeBudgetReport {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+
+	report := TimeBudgetReport{
+		Queries: budget.queries,
+		Phases: []PhaseTime{
+			{Phase: "dns", Time: budget.total.DNS},
+			{Phase: "connect", Time: budget.total.Connect},
+			{Phase: "tls_handshake", Time: budget.total.TLSHandshake},
+			{Phase: "first_byte", Time: budget.total.FirstByte},
+			{Phase: "body_read", Time: budget.total.BodyRead},
+		},
+	}
+	for _, phase := range report.Phases {
+		report.Total += phase.Time
+	}
+	if report.Total > 0 {
+		for i := range report.Phases {
+			report.Phases[i].Fraction = float64(report.Phases[i].Time) / float64(report.Total)
+		}
+	}
+	return report
+}
+
+// Return the fraction of the total time attributable to the responder (the
+// time to first byte), between 0 and 1.  The remainder is attributable to the network.
+func (report *TimeBudgetReport) ResponderFraction() float64 {
+	for _, phase := range report.Phases {
+		if phase.Phase == "first_byte" {
+			return phase.Fraction
+		}
+	}
+	return 0
+}
+
+// Return a human-readable table of the time spent in each phase
+func (report *TimeBudgetReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d queries, %s total\n", report.Queries, report.Total)
+	for _, phase := range report.Phases {
+		fmt.Fprintf(&b, "  %-14s %12s  %5.1f%%\n", phase.Phase, phase.Time, 100*phase.Fraction)
+	}
+	fmt.Fprintf(&b, "  network %.1f%%, responder %.1f%%\n", 100*(1-report.ResponderFraction()), 100*report.ResponderFraction())
+	return b.String()
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"crypto/x509"
+	"time"
+)
+
+// Identifies the certificate to which a cached OCSP response pertains
+type CacheKey struct {
+	IssuerKeyHash string // Hex-encoded SHA-256 hash of the issuer's SubjectPublicKeyInfo
+	SerialNumber  string // Hex-encoded serial number of the certificate
+}
+
+// Return the cache key for the given certificate and issuer
+func MakeCacheKey(cert *x509.Certificate, issuerCert *x509.Certificate) CacheKey {
+	return CacheKey{
+		IssuerKeyHash: IssuerID(issuerCert),
+		SerialNumber:  cert.SerialNumber.Text(16),
+	}
+}
+
+// A cached OCSP response
+type CacheEntry struct {
+	ResponseBytes []byte
+	ThisUpdate    time.Time
+	NextUpdate    time.Time
+}
+
+// Stores OCSP responses so they can be reused instead of querying the responder.
+// Implementations must be safe for concurrent use.  Only responses which have been
+// validated and which contain nextUpdate are stored.
+type Cache interface {
+	Get(key CacheKey) (entry CacheEntry, ok bool)
+	Put(key CacheKey, entry CacheEntry)
+}
+
+// An in-memory implementation of [Cache].  Entries are discarded once their nextUpdate
+// time has passed, and entries without a nextUpdate time are not stored.  The zero
+// value is an empty cache ready to use.
+type MemoryCache struct {
+	// If non-zero, the maximum number of entries.  When the cache is full, Put
+	// discards the least recently used entry.
+	MaxEntries int
+
+	entries cacheMap[CacheKey, CacheEntry]
+}
+
+// Statistics about a [MemoryCache], [IssuerCache], or [CRLCache], as returned by
+// their Stats methods.  A high CapacityEvictions count relative to Hits suggests
+// that the cache's MaxEntries is too small for the workload.
+type CacheStats struct {
+	Entries           int   // The number of entries currently in the cache
+	Bytes             int64 // The total size of the responses, certificates, or CRLs currently in the cache
+	Hits              int64 // The number of lookups which found an entry
+	Misses            int64 // The number of lookups which didn't find an entry
+	Evictions         int64 // The number of entries discarded because their nextUpdate time passed
+	CapacityEvictions int64 // The number of entries discarded to stay within MaxEntries
+}
+
+// Return the fraction of lookups which found an entry, or 0 if there haven't been any
+func (stats CacheStats) HitRate() float64 {
+	if total := stats.Hits + stats.Misses; total > 0 {
+		return float64(stats.Hits) / float64(total)
+	} else {
+		return 0
+	}
+}
+
+func (cache *MemoryCache) Get(key CacheKey) (CacheEntry, bool) {
+	return cache.entries.get(key)
+}
+
+func (cache *MemoryCache) Put(key CacheKey, entry CacheEntry) {
+	if entry.NextUpdate.IsZero() {
+		return
+	}
+	cache.entries.put(key, entry, int64(len(entry.ResponseBytes)), entry.NextUpdate, cache.MaxEntries)
+}
+
+// Return statistics about the cache, e.g. for exporting as metrics
+func (cache *MemoryCache) Stats() CacheStats {
+	return cache.entries.statistics()
+}
+
+// Return true if entry is fresh enough to use instead of querying the responder
+func (entry *CacheEntry) isFresh(fraction float64, now time.Time) bool {
+	validity := entry.NextUpdate.Sub(entry.ThisUpdate)
+	refreshAt := entry.ThisUpdate.Add(time.Duration(fraction * float64(validity)))
+	return now.Before(refreshAt)
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestEvaluateCache(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := new(MemoryCache)
+	config := &Config{Cache: cache}
+
+	first := Evaluate(context.Background(), cert.Raw, responder.CA.RawSubject, responder.CA.RawSubjectPublicKeyInfo, config)
+	if first.Err != nil {
+		t.Fatal(first.Err)
+	}
+	if first.Response.FromCache {
+		t.Errorf("first evaluation was served from the cache")
+	}
+
+	second := Evaluate(context.Background(), cert.Raw, responder.CA.RawSubject, responder.CA.RawSubjectPublicKeyInfo, config)
+	if second.Err != nil {
+		t.Fatal(second.Err)
+	}
+	if !second.Response.FromCache {
+		t.Errorf("second evaluation wasn't served from the cache")
+	}
+	if requests := responder.Requests(); requests != 1 {
+		t.Errorf("responder received %d requests; want 1", requests)
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 1 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("cache stats are %+v; want 1 entry, 1 hit, and 1 miss", stats)
+	}
+	if stats.HitRate() != 0.5 {
+		t.Errorf("hit rate is %v; want 0.5", stats.HitRate())
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := new(MemoryCache)
+	key := MakeCacheKey(cert, responder.CA)
+	cache.Put(key, CacheEntry{ResponseBytes: []byte("response"), NextUpdate: time.Now().Add(-time.Minute)})
+
+	if _, ok := cache.Get(key); ok {
+		t.Errorf("Get returned an entry whose nextUpdate has passed")
+	}
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Bytes != 0 || stats.Evictions != 1 {
+		t.Errorf("cache stats are %+v; want an empty cache with 1 eviction", stats)
+	}
+}
+
+func TestMemoryCacheMaxEntries(t *testing.T) {
+	cache := &MemoryCache{MaxEntries: 2}
+	nextUpdate := time.Now().Add(time.Hour)
+	keys := []CacheKey{{SerialNumber: "1"}, {SerialNumber: "2"}, {SerialNumber: "3"}}
+
+	cache.Put(keys[0], CacheEntry{ResponseBytes: []byte("one"), NextUpdate: nextUpdate})
+	cache.Put(keys[1], CacheEntry{ResponseBytes: []byte("two"), NextUpdate: nextUpdate})
+	cache.Get(keys[0]) // Makes keys[1] the least recently used
+	cache.Put(keys[2], CacheEntry{ResponseBytes: []byte("three"), NextUpdate: nextUpdate})
+
+	if _, ok := cache.Get(keys[1]); ok {
+		t.Errorf("least recently used entry wasn't discarded")
+	}
+	for _, key := range []CacheKey{keys[0], keys[2]} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("entry %s was discarded", key.SerialNumber)
+		}
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Bytes != 8 || stats.CapacityEvictions != 1 {
+		t.Errorf("cache stats are %+v; want 2 entries, 8 bytes, and 1 capacity eviction", stats)
+	}
+}
+
+func TestIssuerCacheStats(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{IssuerCache: &IssuerCache{MaxEntries: 1}}
+
+	for i := 0; i < 2; i++ {
+		issuer, err := FetchIssuer(context.Background(), cert, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !issuer.Equal(responder.CA) {
+			t.Fatalf("FetchIssuer returned the wrong issuer")
+		}
+	}
+	config.IssuerCache.put("http://other.example.com", []*x509.Certificate{responder.CA})
+
+	stats := config.IssuerCache.Stats()
+	if stats.Entries != 1 || stats.Bytes != int64(len(responder.CA.Raw)) || stats.Hits != 1 || stats.Misses != 1 || stats.CapacityEvictions != 1 {
+		t.Errorf("cache stats are %+v; want 1 entry, 1 hit, 1 miss, and 1 capacity eviction", stats)
+	}
+}
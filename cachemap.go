@@ -0,0 +1,123 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// The entries of one of the built-in caches, which are discarded once they
+// expire, or if the cache is full, when they are the least recently used.
+// The zero value is empty and ready to use.
+type cacheMap[K comparable, V any] struct {
+	mu        sync.Mutex
+	entries   map[K]*list.Element // Values are *cacheMapEntry[K, V]
+	lru       list.List           // The most recently used entry is at the front
+	stats     CacheStats
+	lastSweep time.Time
+}
+
+type cacheMapEntry[K comparable, V any] struct {
+	key    K
+	value  V
+	size   int64
+	expiry time.Time // Zero if the entry never expires
+}
+
+func (entry *cacheMapEntry[K, V]) expired(now time.Time) bool {
+	return !entry.expiry.IsZero() && now.After(entry.expiry)
+}
+
+// How often put scans the cache for expired entries
+const cacheSweepInterval = time.Minute
+
+func (m *cacheMap[K, V]) get(key K) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.entries[key]; ok {
+		if entry := elem.Value.(*cacheMapEntry[K, V]); entry.expired(time.Now()) {
+			m.remove(elem)
+			m.stats.Evictions++
+		} else {
+			m.lru.MoveToFront(elem)
+			m.stats.Hits++
+			return entry.value, true
+		}
+	}
+	m.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+// Add an entry of the given size in bytes, replacing any existing entry for
+// key.  If maxEntries is non-zero, the least recently used entries are
+// discarded so that there are at most maxEntries.
+func (m *cacheMap[K, V]) put(key K, value V, size int64, expiry time.Time, maxEntries int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = make(map[K]*list.Element)
+	}
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+	m.entries[key] = m.lru.PushFront(&cacheMapEntry[K, V]{key: key, value: value, size: size, expiry: expiry})
+	m.stats.Entries++
+	m.stats.Bytes += size
+
+	// Expired entries are discarded by get, but entries which are never looked
+	// up again would otherwise accumulate
+	if now := time.Now(); now.Sub(m.lastSweep) >= cacheSweepInterval {
+		for _, elem := range m.entries {
+			if elem.Value.(*cacheMapEntry[K, V]).expired(now) {
+				m.remove(elem)
+				m.stats.Evictions++
+			}
+		}
+		m.lastSweep = now
+	}
+	for maxEntries > 0 && m.lru.Len() > maxEntries {
+		m.remove(m.lru.Back())
+		m.stats.CapacityEvictions++
+	}
+}
+
+func (m *cacheMap[K, V]) remove(elem *list.Element) {
+	entry := m.lru.Remove(elem).(*cacheMapEntry[K, V])
+	delete(m.entries, entry.key)
+	m.stats.Entries--
+	m.stats.Bytes -= entry.size
+}
+
+func (m *cacheMap[K, V]) statistics() CacheStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
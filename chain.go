@@ -0,0 +1,214 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// Specifies how [CheckVerifiedChains] treats certificates whose revocation
+// status can't be determined.
+type FailurePolicy int
+
+const (
+	// Treat certificates whose revocation status can't be determined as unrevoked
+	SoftFail FailurePolicy = iota
+
+	// Reject chains containing a certificate whose revocation status can't be determined
+	HardFail
+)
+
+var (
+	// ErrChainRevoked is returned when every verified chain contains a revoked certificate
+	ErrChainRevoked = errors.New("Every verified chain contains a revoked certificate")
+
+	// ErrChainUnchecked is returned under [HardFail] when no verified chain could be fully checked
+	ErrChainUnchecked = errors.New("Revocation status of every verified chain could not be determined")
+)
+
+// A certificate in a chain, annotated with its revocation status by [CheckChain]
+type ChainCertificate struct {
+	Certificate *x509.Certificate
+
+	// The result of [CheckRevocation], or nil for the last certificate in the chain
+	// (the trust anchor), which isn't checked
+	Result *RevocationResult
+
+	// Non-nil if the certificate's revocation status couldn't be determined
+	Err error
+}
+
+// Report whether the certificate's revocation status was determined to be revoked
+func (cc *ChainCertificate) Revoked() bool {
+	return cc.Result != nil && cc.Result.Revoked
+}
+
+// Given a certificate chain, ordered from leaf to trust anchor (such as one of the chains
+// returned by [x509.Certificate.Verify]), check the revocation status of every certificate
+// except the trust anchor using [CheckRevocation], and return the annotated chain.
+//
+// Certificates which are OCSP responder certificates with the OCSP No Check extension
+// are treated as unrevoked.
+//
+// If config is nil, a zero-value [Config] is used, which provides
+// sensible defaults.
+func CheckChain(ctx context.Context, chain []*x509.Certificate, config *Config) []ChainCertificate {
+	return checkChain(ctx, chain, config, make(map[[2]string]ChainCertificate))
+}
+
+func checkChain(ctx context.Context, chain []*x509.Certificate, config *Config, checked map[[2]string]ChainCertificate) []ChainCertificate {
+	annotated := make([]ChainCertificate, len(chain))
+	for i, cert := range chain {
+		if i == len(chain)-1 {
+			annotated[i] = ChainCertificate{Certificate: cert}
+			break
+		}
+		issuer := chain[i+1]
+		key := [2]string{string(cert.Raw), string(issuer.Raw)}
+		if cc, ok := checked[key]; ok {
+			annotated[i] = cc
+			continue
+		}
+		result, err := CheckRevocation(ctx, cert, issuer, config)
+		if errors.Is(err, ErrNoCheck) {
+			err = nil
+		}
+		annotated[i] = ChainCertificate{Certificate: cert, Result: &result, Err: err}
+		checked[key] = annotated[i]
+	}
+	return annotated
+}
+
+// Given the chains returned by [x509.Certificate.Verify], check them in order
+// using [CheckChain], and return the first chain which contains no revoked
+// certificates.  Under [HardFail] (as specified by [Config].FailurePolicy),
+// chains containing a certificate whose revocation status couldn't be determined
+// are also skipped.  Results for certificates which appear in several chains with
+// the same issuer are reused.
+//
+// If no chain is acceptable, the first annotated chain is returned along with
+// [ErrChainRevoked] if every chain contains a revoked certificate, or an error
+// wrapping [ErrChainUnchecked] otherwise.
+//
+// If config is nil, a zero-value [Config] is used, which provides
+// sensible defaults.
+func CheckVerifiedChains(ctx context.Context, chains [][]*x509.Certificate, config *Config) ([]ChainCertificate, error) {
+	if len(chains) == 0 {
+		return nil, errors.New("no chains to check")
+	}
+
+	var (
+		checked    = make(map[[2]string]ChainCertificate)
+		first      []ChainCertificate
+		uncheckErr error
+	)
+	for _, chain := range chains {
+		annotated := checkChain(ctx, chain, config, checked)
+		if first == nil {
+			first = annotated
+		}
+		if revoked, checkErr := chainStatus(annotated); revoked {
+			continue
+		} else if checkErr != nil && config.failurePolicy() == HardFail {
+			if uncheckErr == nil {
+				uncheckErr = checkErr
+			}
+			continue
+		}
+		return annotated, nil
+	}
+	if uncheckErr != nil {
+		return first, fmt.Errorf("%w: %s", ErrChainUnchecked, uncheckErr)
+	}
+	return first, ErrChainRevoked
+}
+
+// Return whether the chain contains a revoked certificate, and the first error
+// encountered while checking it
+func chainStatus(chain []ChainCertificate) (revoked bool, err error) {
+	for i := range chain {
+		if chain[i].Revoked() {
+			revoked = true
+		}
+		if chain[i].Err != nil && err == nil {
+			err = chain[i].Err
+		}
+	}
+	return
+}
+
+// Return a function suitable for use as [crypto/tls.Config].VerifyPeerCertificate
+// on a server which authenticates clients with certificates.  The function checks
+// the revocation status of the client's verified chains using [CheckVerifiedChains],
+// and rejects the handshake if it returns an error, so [Config].FailurePolicy
+// determines whether clients are rejected when the status can't be determined.
+//
+// The server's ClientAuth must be [crypto/tls.VerifyClientCertIfGiven] or
+// [crypto/tls.RequireAndVerifyClientCert], since chains are only checked if
+// crypto/tls has verified them.  Handshakes without a client certificate are
+// not affected.
+//
+// Responses are cached in [Config].Cache, or if it is nil, in a [MemoryCache]
+// shared by every call of the returned function, so that a client which
+// reconnects doesn't cause another query.  Likewise, CRLs are cached in
+// [Config].CRLCache, or if it is nil, in a shared [CRLCache], so that a CRL
+// isn't downloaded during every handshake.
+//
+// Since the check delays the handshake, it's limited to the maximum query
+// timeout ([Config].PhaseTimeouts.Query, or [QueryTimeout]) in total, across
+// every certificate, OCSP query, and CRL download; certificates which haven't
+// been checked by then are treated according to [Config].FailurePolicy.
+//
+// If config is nil, a zero-value [Config] is used, which provides
+// sensible defaults.
+func VerifyClientCertificates(config *Config) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	cachingConfig := new(Config)
+	if config != nil {
+		*cachingConfig = *config
+	}
+	if cachingConfig.Cache == nil {
+		cachingConfig.Cache = new(MemoryCache)
+	}
+	if cachingConfig.CRLCache == nil {
+		cachingConfig.CRLCache = new(CRLCache)
+	}
+	config = cachingConfig
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), config.maxQueryTimeout())
+		defer cancel()
+		if _, err := CheckVerifiedChains(ctx, verifiedChains, config); err != nil {
+			return fmt.Errorf("client certificate rejected: %w", err)
+		}
+		return nil
+	}
+}
@@ -27,14 +27,23 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
-	"encoding/pem"
 	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
 
+	"golang.org/x/crypto/ocsp"
 	"software.sslmate.com/src/ocsputil"
 )
 
@@ -43,22 +52,40 @@ func readChain(in io.Reader) ([]*x509.Certificate, error) {
 	if err != nil {
 		return nil, err
 	}
-	certs := make([]*x509.Certificate, 0, 0)
-	for len(inBytes) > 0 {
-		block, rest := pem.Decode(inBytes)
-		if block == nil {
-			return nil, errors.New("invalid PEM")
-		}
-		if block.Type == "CERTIFICATE" {
-			cert, err := x509.ParseCertificate(block.Bytes)
-			if err != nil {
-				return nil, err
-			}
-			certs = append(certs, cert)
-		}
-		inBytes = rest
+	return ocsputil.ParseCertificates(inBytes)
+}
+
+func readPKCS12(filename string, password string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ocsputil.ParsePKCS12(data, password)
+}
+
+// The maximum time spent connecting to a TLS server with -connect, including the handshake
+const connectTimeout = 10 * time.Second
+
+func connectChain(ctx context.Context, address string) ([]*x509.Certificate, []byte, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	dialer := &tls.Dialer{
+		Config: &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true, // we want to evaluate the chain even if it doesn't verify
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, nil, err
 	}
-	return certs, nil
+	defer conn.Close()
+	state := conn.(*tls.Conn).ConnectionState()
+	return state.PeerCertificates, state.OCSPResponse, nil
 }
 
 func errString(err error) *string {
@@ -70,29 +97,487 @@ func errString(err error) *string {
 	}
 }
 
-func main() {
-	chain, err := readChain(os.Stdin)
+func errCode(err error) *string {
 	if err != nil {
-		log.Fatalf("Error reading certificate chain from stdin: %s", err)
+		code := string(ocsputil.ErrorCodeOf(err))
+		return &code
+	} else {
+		return nil
+	}
+}
+
+func optionalString(str string) *string {
+	if str != "" {
+		return &str
+	} else {
+		return nil
 	}
-	if len(chain) < 2 {
-		log.Fatalf("Fewer than 2 certificates provided on stdin")
+}
+
+func hexString(b []byte) *string {
+	if b != nil {
+		str := hex.EncodeToString(b)
+		return &str
+	} else {
+		return nil
+	}
+}
+
+func statusString(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+func formatTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	str := t.UTC().Format(time.RFC3339)
+	return &str
+}
+
+func detailsObject(details *ocsputil.ResponseDetails) map[string]interface{} {
+	if details == nil {
+		return nil
+	}
+	object := map[string]interface{}{
+		"status":            statusString(details.Status),
+		"produced_at":       formatTime(details.ProducedAt),
+		"this_update":       formatTime(details.ThisUpdate),
+		"next_update":       formatTime(details.NextUpdate),
+		"revocation_time":   nil,
+		"revocation_reason": nil,
+	}
+	if details.Revoked() {
+		object["revocation_time"] = formatTime(details.RevocationInfo.Time)
+		object["revocation_reason"] = details.RevocationInfo.Reason
+	}
+	return object
+}
+
+func tlsObject(info *ocsputil.TLSInfo) map[string]interface{} {
+	if info == nil {
+		return nil
+	}
+	object := map[string]interface{}{
+		"version":      info.VersionName(),
+		"cipher_suite": info.CipherSuiteName(),
+		"deprecation":  nil,
+	}
+	if deprecation := info.Deprecation(); deprecation != "" {
+		object["deprecation"] = deprecation
+	}
+	return object
+}
+
+func writeJSON(out io.Writer, eval ocsputil.Evaluation, includeDetails bool, staple []byte, connected bool) {
+	object := map[string]interface{}{
+		"responder_url":        eval.ResponderURL,
+		"responder_url_reason": optionalString(eval.ResponderURLReason),
+		"request_bytes":        eval.RequestBytes,
+		"method":               eval.Method,
+		"response_bytes":       eval.ResponseBytes,
+		"response_hash":        hexString(eval.ResponseHash),
+		"response_time":        eval.ResponseTime.String(),
+		"attempts":             eval.Attempts,
+		"tls":                  tlsObject(eval.TLS),
+		"vantage":              eval.Vantage,
+		"domain_names":         eval.DomainNames,
+		"findings":             eval.Findings,
+		"error":                errString(eval.Err),
+		"error_code":           errCode(eval.Err),
+	}
+	if includeDetails {
+		object["response"] = detailsObject(eval.Response)
+	}
+	if connected {
+		object["stapled_response"] = staple
 	}
-	var (
-		certData      = chain[0].Raw
-		issuerSubject = chain[1].RawSubject
-		issuerPubkey  = chain[1].RawSubjectPublicKeyInfo
-	)
-	eval := ocsputil.Evaluate(context.Background(), certData, issuerSubject, issuerPubkey, nil)
 
-	encoder := json.NewEncoder(os.Stdout)
+	encoder := json.NewEncoder(out)
 	encoder.SetEscapeHTML(false)
 	encoder.SetIndent("", "\t")
-	encoder.Encode(map[string]interface{}{
-		"responder_url":  eval.ResponderURL,
-		"request_bytes":  eval.RequestBytes,
-		"response_bytes": eval.ResponseBytes,
-		"response_time":  eval.ResponseTime.String(),
-		"error":          errString(eval.Err),
-	})
+	encoder.Encode(object)
+}
+
+func writeText(out io.Writer, eval ocsputil.Evaluation, staple []byte, connected bool) {
+	if eval.ResponderURL != nil {
+		fmt.Fprintf(out, "Responder URL:  %s (%s)\n", *eval.ResponderURL, eval.ResponderURLReason)
+	}
+	if eval.Method != nil {
+		fmt.Fprintf(out, "Method:         %s\n", *eval.Method)
+	}
+	if eval.ResponseBytes != nil {
+		fmt.Fprintf(out, "Response time:  %s\n", eval.ResponseTime)
+	}
+	if eval.TLS != nil {
+		fmt.Fprintf(out, "TLS:            %s, %s\n", eval.TLS.VersionName(), eval.TLS.CipherSuiteName())
+	}
+	if eval.Attempts > 1 {
+		fmt.Fprintf(out, "Attempts:       %d\n", eval.Attempts)
+	}
+	if eval.ResponseHash != nil {
+		fmt.Fprintf(out, "Response hash:  %x\n", eval.ResponseHash)
+	}
+	if details := eval.Response; details != nil {
+		fmt.Fprintf(out, "Status:         %s\n", statusString(details.Status))
+		if details.Revoked() {
+			fmt.Fprintf(out, "Revoked at:     %s (reason %d)\n", details.RevocationInfo.Time.UTC().Format(time.RFC3339), details.RevocationInfo.Reason)
+		}
+		fmt.Fprintf(out, "Produced at:    %s\n", details.ProducedAt.UTC().Format(time.RFC3339))
+		fmt.Fprintf(out, "This update:    %s\n", details.ThisUpdate.UTC().Format(time.RFC3339))
+		if !details.NextUpdate.IsZero() {
+			fmt.Fprintf(out, "Next update:    %s\n", details.NextUpdate.UTC().Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(out, "Next update:    (none)\n")
+		}
+	}
+	if connected {
+		if staple != nil {
+			fmt.Fprintf(out, "Stapled:        yes (%d bytes)\n", len(staple))
+		} else {
+			fmt.Fprintf(out, "Stapled:        no\n")
+		}
+	}
+	if len(eval.DomainNames) > 0 {
+		fmt.Fprintf(out, "Domain names:   %s\n", strings.Join(eval.DomainNames, ", "))
+	}
+	vantageKeys := make([]string, 0, len(eval.Vantage))
+	for key := range eval.Vantage {
+		vantageKeys = append(vantageKeys, key)
+	}
+	sort.Strings(vantageKeys)
+	for _, key := range vantageKeys {
+		fmt.Fprintf(out, "Vantage:        %s=%s\n", key, eval.Vantage[key])
+	}
+	for _, finding := range eval.Findings {
+		fmt.Fprintf(out, "Finding:        [%s] %s: %s\n", finding.Severity, finding.Lint, finding.Message)
+	}
+	if eval.Err != nil {
+		fmt.Fprintf(out, "Error:          %s\n", eval.Err)
+		fmt.Fprintf(out, "Error code:     %s\n", ocsputil.ErrorCodeOf(eval.Err))
+	} else {
+		fmt.Fprintf(out, "Result:         OK\n")
+	}
+}
+
+// The data passed to a -format template.  Status, ResponseHash, Error, and
+// ErrorCode are provided in string form for convenience.
+type templateData struct {
+	ocsputil.Evaluation
+	Status          string // "good", "revoked", "unknown", or empty if there's no verified response
+	ResponseHash    string // Hex-encoded, or empty if there's no response
+	Error           string // Empty if there was no error
+	ErrorCode       string // One of the codes printed by -list-error-codes, or empty if there was no error
+	StapledResponse []byte // Only with -connect
+}
+
+func writeTemplate(out io.Writer, tmpl *template.Template, eval ocsputil.Evaluation, staple []byte) error {
+	data := templateData{
+		Evaluation:      eval,
+		ResponseHash:    hex.EncodeToString(eval.ResponseHash),
+		StapledResponse: staple,
+	}
+	if eval.Response != nil {
+		data.Status = statusString(eval.Response.Status)
+	}
+	if eval.Err != nil {
+		data.Error = eval.Err.Error()
+		data.ErrorCode = string(ocsputil.ErrorCodeOf(eval.Err))
+	}
+	if err := tmpl.Execute(out, data); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+func listErrorCodes(out io.Writer, format string) {
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "\t")
+		encoder.Encode(ocsputil.ErrorCodes)
+		return
+	}
+	for _, info := range ocsputil.ErrorCodes {
+		fmt.Fprintf(out, "%-20s %s\n", info.Code, info.Description)
+	}
+}
+
+func listLints(out io.Writer, format string) {
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "\t")
+		encoder.Encode(ocsputil.Lints)
+		return
+	}
+	for _, info := range ocsputil.Lints {
+		fmt.Fprintf(out, "%-36s %-8s %s\n", info.Name, info.Severity, info.Description)
+	}
+}
+
+// Return the issuer of the first certificate in chain, downloading it if
+// the chain doesn't include it
+func chainIssuer(ctx context.Context, chain []*x509.Certificate, config *ocsputil.Config) *x509.Certificate {
+	if len(chain) > 1 {
+		return chain[1]
+	}
+	issuer, err := ocsputil.FetchIssuer(ctx, chain[0], config)
+	if err != nil {
+		log.Fatalf("Error fetching issuer: %s", err)
+	}
+	return issuer
+}
+
+func runProbe(chain []*x509.Certificate, config *ocsputil.Config, format string, tmpl *template.Template) {
+	ctx := context.Background()
+	cert := chain[0]
+	issuer := chainIssuer(ctx, chain, config)
+
+	report, err := ocsputil.ProbeResponder(ctx, cert, issuer, config)
+	if err != nil {
+		log.Fatalf("Error probing OCSP responder: %s", err)
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "\t")
+		encoder.Encode(report)
+	case "text":
+		fmt.Printf("Responder URL:    %s\n", report.ResponderURL)
+		for _, check := range []struct {
+			name   string
+			result ocsputil.ProbeResult
+		}{
+			{"POST", report.POST},
+			{"GET", report.GET},
+			{"SHA-256 CertID", report.SHA256CertID},
+			{"Nonce", report.Nonce},
+			{"Caching headers", report.CachingHeaders},
+			{"Unissued serial", report.UnissuedSerial},
+			{"Redundancy", report.Redundancy},
+			{"Keep-alive", report.KeepAlive},
+		} {
+			verdict := "FAIL"
+			if check.result.OK {
+				verdict = "OK"
+			}
+			if check.result.Detail != "" {
+				fmt.Printf("%-17s %s (%s)\n", check.name+":", verdict, check.result.Detail)
+			} else {
+				fmt.Printf("%-17s %s\n", check.name+":", verdict)
+			}
+		}
+		fmt.Printf("Latency:          min %s, median %s, max %s (%d samples)\n", report.Latency.Min, report.Latency.Median, report.Latency.Max, report.Latency.Samples)
+		for _, finding := range report.Findings {
+			fmt.Printf("Finding:          [%s] %s: %s\n", finding.Severity, finding.Lint, finding.Message)
+		}
+	default:
+		if err := tmpl.Execute(os.Stdout, report); err != nil {
+			log.Fatalf("Error executing -format template: %s", err)
+		}
+		fmt.Println()
+	}
+}
+
+func runMirror(chain []*x509.Certificate, mirrorURL string, config *ocsputil.Config, format string, tmpl *template.Template) {
+	ctx := context.Background()
+	comparison, err := ocsputil.MirrorQuery(ctx, chain[0], chainIssuer(ctx, chain, config), mirrorURL, config)
+	if err != nil {
+		log.Fatalf("Error querying OCSP responders: %s", err)
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "\t")
+		encoder.Encode(comparison)
+	case "text":
+		for _, side := range []struct {
+			name   string
+			result ocsputil.MirrorResult
+		}{
+			{"Primary", comparison.Primary},
+			{"Mirror", comparison.Mirror},
+		} {
+			fmt.Printf("%-9s %s\n", side.name+":", side.result.ResponderURL)
+			if side.result.Status != "" {
+				fmt.Printf("  Status:        %s\n", side.result.Status)
+			}
+			fmt.Printf("  Response time: %s\n", side.result.ResponseTime)
+			if side.result.Error != "" {
+				fmt.Printf("  Error:         %s (%s)\n", side.result.Error, side.result.ErrorCode)
+			}
+		}
+		if comparison.Agree() {
+			fmt.Printf("Result:   agree\n")
+		} else {
+			fmt.Printf("Result:   MISMATCH (%s)\n", comparison.Mismatch)
+		}
+	default:
+		if err := tmpl.Execute(os.Stdout, comparison); err != nil {
+			log.Fatalf("Error executing -format template: %s", err)
+		}
+		fmt.Println()
+	}
+}
+
+type vantageFlag map[string]string
+
+func (v vantageFlag) String() string {
+	return fmt.Sprint(map[string]string(v))
+}
+
+func (v vantageFlag) Set(arg string) error {
+	fields := strings.SplitN(arg, "=", 2)
+	if len(fields) != 2 {
+		return errors.New("must be of the form KEY=VALUE")
+	}
+	v[fields[0]] = fields[1]
+	return nil
+}
+
+func main() {
+	vantage := make(vantageFlag)
+	method := flag.String("method", "post", "HTTP method for sending the OCSP request: `post`, get, or auto")
+	lint := flag.Bool("lint", false, "Check the OCSP response for Baseline Requirements and RFC 6960 violations")
+	flag.Var(vantage, "vantage", "Attach `KEY=VALUE` metadata about this vantage point to the output (may be repeated)")
+	pkcs12File := flag.String("pkcs12", "", "Read the certificate chain from the PKCS#12 (PFX) file `FILE` instead of stdin")
+	pkcs12Password := flag.String("pkcs12-password", "", "The `PASSWORD` of the -pkcs12 file")
+	connect := flag.String("connect", "", "Retrieve the certificate chain from the TLS server at `HOST:PORT` instead of stdin")
+	format := flag.String("format", "json", "Output format: `json`, text, or a Go template such as '{{.Status}} {{.ResponseTime}}'")
+	details := flag.Bool("details", false, "Include the parsed response status and validity window in the JSON output")
+	retries := flag.Int("retries", 0, "Retry a failed OCSP query up to `N` times")
+	nonce := flag.Bool("nonce", false, "Include a nonce in the OCSP request")
+	expiryWarning := flag.Duration("expiry-warning", 0, "Add a finding if the certificate expires within `DURATION` (e.g. 720h)")
+	strictCertIDHash := flag.Bool("strict-cert-id-hash", false, "Reject responses whose CertID uses a different hash algorithm than the request")
+	allowHTTPS := flag.Bool("allow-https", false, "Use an https:// OCSP responder URL if the certificate lacks an http:// one")
+	mirror := flag.String("mirror", "", "Send the OCSP request to both the certificate's responder and `URL`, and compare the results")
+	probe := flag.Bool("probe", false, "Probe the responder's capabilities and compliance instead of evaluating it")
+	responderIP := flag.String("responder-ip", "", "Connect to the OCSP responder at `IP` instead of resolving its hostname")
+	responseFile := flag.String("response-out", "", "Write the raw DER-encoded OCSP response to `FILE`")
+	listCodes := flag.Bool("list-error-codes", false, "Print the catalog of error codes which may appear in the output, then exit")
+	listLintCatalog := flag.Bool("list-lints", false, "Print the catalog of lints which may appear in findings, then exit")
+	flag.Parse()
+
+	if *listCodes {
+		listErrorCodes(os.Stdout, *format)
+		return
+	}
+	if *listLintCatalog {
+		listLints(os.Stdout, *format)
+		return
+	}
+
+	var tmpl *template.Template
+	if *format != "json" && *format != "text" {
+		var err error
+		tmpl, err = template.New("format").Parse(*format)
+		if err != nil {
+			log.Fatalf("Invalid -format template: %s", err)
+		}
+	}
+
+	var (
+		chain  []*x509.Certificate
+		staple []byte
+		err    error
+	)
+	if *connect != "" && *pkcs12File != "" {
+		log.Fatalf("-connect and -pkcs12 cannot both be specified")
+	}
+	if *connect != "" {
+		chain, staple, err = connectChain(context.Background(), *connect)
+		if err != nil {
+			log.Fatalf("Error retrieving certificate chain from %s: %s", *connect, err)
+		}
+	} else if *pkcs12File != "" {
+		chain, err = readPKCS12(*pkcs12File, *pkcs12Password)
+		if err != nil {
+			log.Fatalf("Error reading certificate chain from %s: %s", *pkcs12File, err)
+		}
+	} else {
+		chain, err = readChain(os.Stdin)
+		if err != nil {
+			log.Fatalf("Error reading certificate chain from stdin: %s", err)
+		}
+	}
+	if len(chain) == 0 {
+		log.Fatalf("No certificates provided")
+	}
+	config := &ocsputil.Config{
+		Lint:             *lint,
+		Retries:          *retries,
+		Nonce:            *nonce,
+		AllowHTTPS:       *allowHTTPS,
+		ExpiryWarning:    *expiryWarning,
+		StrictCertIDHash: *strictCertIDHash,
+	}
+	switch *method {
+	case "post":
+		config.Method = ocsputil.MethodPOST
+	case "get":
+		config.Method = ocsputil.MethodGET
+	case "auto":
+		config.Method = ocsputil.MethodAuto
+	default:
+		log.Fatalf("Invalid -method %q (must be post, get, or auto)", *method)
+	}
+	if len(vantage) > 0 {
+		config.Vantage = vantage
+	}
+	if *responderIP != "" {
+		if config.ResponderIPOverride = net.ParseIP(*responderIP); config.ResponderIPOverride == nil {
+			log.Fatalf("Invalid -responder-ip %q", *responderIP)
+		}
+	}
+
+	if *probe {
+		runProbe(chain, config, *format, tmpl)
+		return
+	}
+	if *mirror != "" {
+		runMirror(chain, *mirror, config, *format, tmpl)
+		return
+	}
+
+	var eval ocsputil.Evaluation
+	if len(chain) == 1 {
+		eval = ocsputil.EvaluateLeaf(context.Background(), chain[0].Raw, config)
+	} else {
+		var (
+			certData      = chain[0].Raw
+			issuerSubject = chain[1].RawSubject
+			issuerPubkey  = chain[1].RawSubjectPublicKeyInfo
+		)
+		eval = ocsputil.Evaluate(context.Background(), certData, issuerSubject, issuerPubkey, config)
+	}
+
+	if *responseFile != "" && eval.ResponseBytes != nil {
+		if err := os.WriteFile(*responseFile, eval.ResponseBytes, 0666); err != nil {
+			log.Fatalf("Error writing OCSP response: %s", err)
+		}
+	}
+
+	switch *format {
+	case "json":
+		writeJSON(os.Stdout, eval, *details, staple, *connect != "")
+	case "text":
+		writeText(os.Stdout, eval, staple, *connect != "")
+	default:
+		if err := writeTemplate(os.Stdout, tmpl, eval, staple); err != nil {
+			log.Fatalf("Error executing -format template: %s", err)
+		}
+	}
 }
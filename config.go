@@ -26,17 +26,209 @@
 package ocsputil
 
 import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"net"
 	"net/http"
+	"time"
 )
 
+// Specifies the HTTP method used to send OCSP requests.
+type Method int
+
+const (
+	// Send OCSP requests using POST
+	MethodPOST Method = iota
+
+	// Send OCSP requests using GET, as described in Appendix A.1 of RFC 6960
+	MethodGET
+
+	// Send OCSP requests using GET if the resulting URL, including the responder
+	// URL, is no longer than 255 bytes (as recommended by RFC 5019), and POST otherwise.  If the responder
+	// rejects the GET request, the query is retried using POST, which has its own
+	// timeout (see [PhaseTimeouts].Query) and is subject to [BatchOptions].ResponderRateLimit.
+	MethodAuto
+)
+
+// Specifies which request and response bodies are kept in an [Evaluation].
+type BodyRetention int
+
+const (
+	// Keep RequestBytes and ResponseBytes in every Evaluation
+	RetainAll BodyRetention = iota
+
+	// Keep RequestBytes and ResponseBytes only in Evaluations whose Err is non-nil
+	RetainErrorsOnly
+
+	// Never keep RequestBytes or ResponseBytes
+	RetainNone
+)
+
+// Limits the amount of time spent in each phase of an OCSP check, so that a slow
+// phase can't consume the budget meant for another.  Each phase is also bounded
+// by the deadline of the context passed to the function performing the check.
+type PhaseTimeouts struct {
+	// The maximum time spent parsing the certificate and issuer.  Parsing is not
+	// interruptible, so this is checked after parsing completes.  Used only by
+	// [Evaluate].  If zero, there is no limit.
+	Parse time.Duration
+
+	// The maximum time spent downloading each issuer certificate in [FetchIssuer].
+	// If zero, [QueryTimeout] is used.
+	AIAFetch time.Duration
+
+	// The maximum time spent on each attempt to query the OCSP responder (see
+	// [Config].Retries), and on the POST retry of [MethodAuto].  If zero, [QueryTimeout] is used.  [Config].AdaptiveTimeout
+	// never exceeds this value.
+	Query time.Duration
+
+	// The maximum time spent verifying the OCSP response.  Verification is not
+	// interruptible, so this is checked after verification completes.  Used only
+	// by [Evaluate].  If zero, there is no limit.
+	Verify time.Duration
+}
+
 // Contains configuration for the functions in this package.
 // The zero value provides sensible defaults.
 type Config struct {
 	// The HTTP client for making OCSP requests. If nil, then [http.DefaultClient] is used.
 	HTTPClient *http.Client
 
+	// If non-nil, OCSP queries connect to this IP address instead of resolving
+	// the responder's hostname, e.g. to test a particular backend, or new
+	// infrastructure before DNS is cut over to it.  The URL, Host header, and TLS
+	// server name are unchanged, and any HTTP proxy is bypassed.  HTTPClient's
+	// transport, if set, must be an [*http.Transport]; its DialContext, if set, is
+	// used to connect.  Connections to the address are reused within an
+	// [EvaluateBatch] or [ProbeResponder], but not otherwise.  Issuer and CRL downloads are not affected.
+	ResponderIPOverride net.IP
+
 	// The HTTP User-Agent string for OCSP requests. If empty, then no User-Agent is sent.
 	UserAgent string
+
+	// The rules against which OCSP responses are judged, selected by the response's
+	// producedAt date using [ProfileAt].  If nil, then [DefaultProfiles] is used.
+	Profiles []Profile
+
+	// Arbitrary metadata describing the vantage point from which OCSP queries
+	// are made (e.g. region, ASN, scanner ID, software version).  It is copied
+	// into every [Evaluation] so results from multiple vantage points can be
+	// told apart.
+	Vantage map[string]string
+
+	// If non-nil, [Query] learns each responder's latency and uses shorter timeouts
+	// for fast responders.  If nil, then every query uses [QueryTimeout].
+	AdaptiveTimeout *AdaptiveTimeout
+
+	// If non-nil, then [CheckCert], [Evaluate], and [Stapler] record the age of every
+	// newly-validated response in it, so that responders with wrong clocks can be identified.
+	SkewTracker *SkewTracker
+
+	// If true, then [CheckCert], [Evaluate], and other functions which find the
+	// responder URL using a Config fall back to an "https://" OCSP responder URL
+	// when the certificate lacks an "http://" one.  The negotiated TLS parameters
+	// are recorded in the [Evaluation].
+	AllowHTTPS bool
+
+	// Chooses the OCSP responder URL when a certificate contains several.  If nil,
+	// the first URL is used.
+	URLSelector *URLSelector
+
+	// The HTTP method used to send OCSP requests.  The zero value is [MethodPOST].
+	Method Method
+
+	// If true, then [Evaluate] runs [Lint] on the OCSP response and includes
+	// the findings in the [Evaluation].
+	Lint bool
+
+	// If non-zero, then [Evaluate] adds a "certificate_expiring" finding to the
+	// [Evaluation] if the certificate's notAfter time is within this duration of
+	// the current time, or has passed.  This is independent of Lint.
+	ExpiryWarning time.Duration
+
+	// If non-nil, then [CheckCert] and [Evaluate] reuse responses from this cache,
+	// and store newly-validated responses in it.
+	Cache Cache
+
+	// The fraction, between 0 and 1, of a cached response's validity interval (from
+	// thisUpdate to nextUpdate) after which the response is no longer reused and the
+	// responder is queried again.  If zero, 0.5 is used.
+	CacheRefreshFraction float64
+
+	// Overrides which apply to certificates issued by particular issuers, keyed
+	// by the issuer ID returned by [IssuerID].  Used by [CheckCert], [Evaluate],
+	// and [Stapler] (but not the low-level functions such as [CreateRequest]).
+	IssuerOverrides map[string]IssuerOverride
+
+	// If non-nil, called with every HTTP request sent to an OCSP responder, just
+	// before it is sent.  It can modify the request, e.g. to add authentication
+	// material such as a bearer token or signed headers for responders behind
+	// gateways.  If it returns an error, the query fails with that error.
+	PrepareRequest func(*http.Request) error
+
+	// If non-nil, called after every HTTP request sent to an OCSP responder
+	// completes, successfully or not, with information about the request that
+	// is useful for exporting metrics.  It may be called concurrently.
+	OnQueryDone func(QueryInfo)
+
+	// If non-nil, then [QueryInfo].DomainNames is populated with the domain names
+	// of the certificate being checked by [CheckCert] or [Evaluate], limited by
+	// DomainLabels to bound the cardinality of metrics labeled by them.
+	DomainLabels *DomainLabels
+
+	// Limits on the time spent in each phase of an OCSP check
+	PhaseTimeouts PhaseTimeouts
+
+	// The number of times a failed OCSP query is retried.  Queries are retried after
+	// transport errors, HTTP 5xx errors, malformed responses, and responses with the
+	// internalError or tryLater status.  If zero, queries are not retried.
+	Retries int
+
+	// The delay before the first retry, which is doubled before each subsequent
+	// retry.  If zero, 1 second is used.
+	RetryBackoff time.Duration
+
+	// The maximum time spent on a query, including every attempt and the delays
+	// between them.  If zero, there is no limit besides the per-attempt timeout.
+	QueryDeadline time.Duration
+
+	// If true, then OCSP requests include a random nonce, and responses which echo
+	// a different nonce are rejected with [ErrNonceMismatch].  Used by [CheckCert],
+	// [Evaluate], and [Stapler] (but not [CreateRequest]).
+	Nonce bool
+
+	// If true, then responses whose CertID was computed using a different hash
+	// algorithm than the request's (see [IssuerOverride].Hash) are rejected with
+	// [ErrCertIDHashMismatch].  Otherwise, such responses are accepted for
+	// compatibility with responders which always answer using one algorithm,
+	// since golang.org/x/crypto/ocsp matches responses by serial number alone;
+	// if Lint is true, [Evaluate] reports the mismatch as a "cert_id_hash_mismatch" finding.
+	StrictCertIDHash bool
+
+	// If non-nil, [FetchIssuer] caches downloaded issuer certificates in it.
+	IssuerCache *IssuerCache
+
+	// Specifies how [CheckVerifiedChains] treats certificates whose revocation
+	// status can't be determined.  The zero value is [SoftFail].
+	FailurePolicy FailurePolicy
+
+	// The maximum size, in bytes, of a CRL downloaded by [CheckCRL].  If zero, 32 MiB is used.
+	MaxCRLSize int64
+
+	// If non-nil, [CheckCRL] caches verified CRLs in it until their nextUpdate time.
+	CRLCache *CRLCache
+
+	// Controls whether [Evaluate] keeps RequestBytes and ResponseBytes in the
+	// [Evaluation].  Discarding bodies saves memory when evaluating many certificates;
+	// ResponseHash is still set.  The zero value is [RetainAll].
+	RetainBodies BodyRetention
+
+	rateLimiter  *rateLimiter  // set by EvaluateBatch
+	pinnedClient *http.Client  // set by EvaluateBatch when ResponderIPOverride is set
+	timeout      time.Duration // set by forIssuer
+	domainNames  []string      // set by forCertificate
 }
 
 func (config *Config) httpClient() *http.Client {
@@ -47,6 +239,25 @@ func (config *Config) httpClient() *http.Client {
 	}
 }
 
+// Return the HTTP client for OCSP queries, which is pinned to ResponderIPOverride
+// if set, and a function to call once the client is no longer needed.  Unless
+// the pinned client belongs to a batch, it's used for just one query, so that
+// its connections aren't left open.
+func (config *Config) queryClient() (*http.Client, func(), error) {
+	switch {
+	case config != nil && config.pinnedClient != nil:
+		return config.pinnedClient, func() {}, nil
+	case config != nil && config.ResponderIPOverride != nil:
+		client, err := pinnedClient(config.httpClient(), config.ResponderIPOverride)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, client.CloseIdleConnections, nil
+	default:
+		return config.httpClient(), func() {}, nil
+	}
+}
+
 func (config *Config) userAgent() string {
 	if config != nil {
 		return config.UserAgent
@@ -54,3 +265,334 @@ func (config *Config) userAgent() string {
 		return ""
 	}
 }
+
+func (config *Config) profiles() []Profile {
+	if config != nil && config.Profiles != nil {
+		return config.Profiles
+	} else {
+		return DefaultProfiles
+	}
+}
+
+// Return a copy of Vantage, so that results aren't affected if the caller
+// modifies Vantage later
+func (config *Config) vantage() map[string]string {
+	if config == nil || config.Vantage == nil {
+		return nil
+	}
+	vantage := make(map[string]string, len(config.Vantage))
+	for key, value := range config.Vantage {
+		vantage[key] = value
+	}
+	return vantage
+}
+
+func (config *Config) maxQueryTimeout() time.Duration {
+	if config != nil && config.PhaseTimeouts.Query != 0 {
+		return config.PhaseTimeouts.Query
+	} else {
+		return QueryTimeout
+	}
+}
+
+func (config *Config) queryTimeout(serverURL string) time.Duration {
+	maxTimeout := config.maxQueryTimeout()
+
+	if config != nil && config.timeout != 0 {
+		return config.timeout
+	} else if config != nil && config.AdaptiveTimeout != nil {
+		return config.AdaptiveTimeout.timeout(serverURL, maxTimeout)
+	}
+	return maxTimeout
+}
+
+func (config *Config) retries() int {
+	if config != nil && config.Retries > 0 {
+		return config.Retries
+	} else {
+		return 0
+	}
+}
+
+func (config *Config) retryBackoff() time.Duration {
+	if config != nil && config.RetryBackoff > 0 {
+		return config.RetryBackoff
+	} else {
+		return time.Second
+	}
+}
+
+func (config *Config) queryDeadline() time.Duration {
+	if config != nil {
+		return config.QueryDeadline
+	} else {
+		return 0
+	}
+}
+
+func (config *Config) nonce() bool {
+	return config != nil && config.Nonce
+}
+
+func (config *Config) strictCertIDHash() bool {
+	return config != nil && config.StrictCertIDHash
+}
+
+// Return the hash algorithm used to compute the CertID in requests about
+// certificates issued by issuerCert
+func (config *Config) certIDHash(issuerCert *x509.Certificate) crypto.Hash {
+	if hash := config.issuerOverride(issuerCert).Hash; hash != 0 {
+		return hash
+	} else {
+		return crypto.SHA1
+	}
+}
+
+func (config *Config) aiaFetchTimeout() time.Duration {
+	if config != nil && config.PhaseTimeouts.AIAFetch != 0 {
+		return config.PhaseTimeouts.AIAFetch
+	} else {
+		return QueryTimeout
+	}
+}
+
+func (config *Config) issuerCache() *IssuerCache {
+	if config != nil {
+		return config.IssuerCache
+	} else {
+		return nil
+	}
+}
+
+func (config *Config) failurePolicy() FailurePolicy {
+	if config != nil {
+		return config.FailurePolicy
+	} else {
+		return SoftFail
+	}
+}
+
+func (config *Config) crlCache() *CRLCache {
+	if config != nil {
+		return config.CRLCache
+	} else {
+		return nil
+	}
+}
+
+func (config *Config) maxCRLSize() int64 {
+	if config != nil && config.MaxCRLSize > 0 {
+		return config.MaxCRLSize
+	} else {
+		return 32 << 20
+	}
+}
+
+func (config *Config) parseTimeout() time.Duration {
+	if config != nil {
+		return config.PhaseTimeouts.Parse
+	} else {
+		return 0
+	}
+}
+
+func (config *Config) verifyTimeout() time.Duration {
+	if config != nil {
+		return config.PhaseTimeouts.Verify
+	} else {
+		return 0
+	}
+}
+
+func (config *Config) observeLatency(serverURL string, latency time.Duration) {
+	if config != nil && config.AdaptiveTimeout != nil {
+		config.AdaptiveTimeout.Observe(serverURL, latency)
+	}
+}
+
+// Record a query which failed without a response as taking the whole time it was
+// allowed, unless it was canceled by the caller.  Otherwise, once the adaptive
+// timeout shrinks, a responder which slows down would only ever time out, and the
+// timeout would never grow back.
+func (config *Config) observeFailure(ctx context.Context, serverURL string, startTime time.Time) {
+	if config == nil || config.AdaptiveTimeout == nil || errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	timeout := config.queryTimeout(serverURL)
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = deadline.Sub(startTime)
+	}
+	config.AdaptiveTimeout.Observe(serverURL, timeout)
+}
+
+func (config *Config) observeSkew(serverURL string, details *ResponseDetails) {
+	if config != nil && config.SkewTracker != nil {
+		config.SkewTracker.Observe(serverURL, details.ProducedAt, time.Now())
+	}
+}
+
+func (config *Config) method() Method {
+	if config != nil {
+		return config.Method
+	} else {
+		return MethodPOST
+	}
+}
+
+func (config *Config) expiryWarning() time.Duration {
+	if config != nil {
+		return config.ExpiryWarning
+	} else {
+		return 0
+	}
+}
+
+func (config *Config) allowHTTPS() bool {
+	return config != nil && config.AllowHTTPS
+}
+
+func (config *Config) lint() bool {
+	return config != nil && config.Lint
+}
+
+func (config *Config) retainBodies(err error) bool {
+	if config == nil {
+		return true
+	}
+	switch config.RetainBodies {
+	case RetainErrorsOnly:
+		return err != nil
+	case RetainNone:
+		return false
+	default:
+		return true
+	}
+}
+
+func (config *Config) cacheRefreshFraction() float64 {
+	if config != nil && config.CacheRefreshFraction > 0 && config.CacheRefreshFraction <= 1 {
+		return config.CacheRefreshFraction
+	} else {
+		return 0.5
+	}
+}
+
+// Return a fresh, validated response from the cache, or nil if there isn't one
+func (config *Config) cachedResponse(cert *x509.Certificate, issuerCert *x509.Certificate) ([]byte, *ResponseDetails) {
+	if config == nil || config.Cache == nil {
+		return nil, nil
+	}
+	entry, ok := config.Cache.Get(MakeCacheKey(cert, issuerCert))
+	if !ok || !entry.isFresh(config.cacheRefreshFraction(), time.Now()) {
+		return nil, nil
+	}
+	details, err := checkResponseDetails(cert, issuerCert, entry.ResponseBytes, config)
+	if err != nil {
+		return nil, nil
+	}
+	details.FromCache = true
+	return entry.ResponseBytes, details
+}
+
+func (config *Config) cacheResponse(cert *x509.Certificate, issuerCert *x509.Certificate, responseBytes []byte, details *ResponseDetails) {
+	if config == nil || config.Cache == nil || details.NextUpdate.IsZero() {
+		return
+	}
+	config.Cache.Put(MakeCacheKey(cert, issuerCert), CacheEntry{
+		ResponseBytes: responseBytes,
+		ThisUpdate:    details.ThisUpdate,
+		NextUpdate:    details.NextUpdate,
+	})
+}
+
+func (config *Config) issuerOverride(issuerCert *x509.Certificate) IssuerOverride {
+	if config != nil && config.IssuerOverrides != nil {
+		return config.IssuerOverrides[IssuerID(issuerCert)]
+	} else {
+		return IssuerOverride{}
+	}
+}
+
+// Return a copy of config with the Method and Timeout overrides for issuerCert applied,
+// since query doesn't know which issuer it's querying for
+func (config *Config) forIssuer(issuerCert *x509.Certificate) *Config {
+	override := config.issuerOverride(issuerCert)
+	if override.Method == nil && override.Timeout == 0 {
+		return config
+	}
+	issuerConfig := new(Config)
+	if config != nil {
+		*issuerConfig = *config
+	}
+	if override.Method != nil {
+		issuerConfig.Method = *override.Method
+	}
+	issuerConfig.timeout = override.Timeout
+	return issuerConfig
+}
+
+// Return a copy of config which labels queries with cert's domain names, if
+// config.DomainLabels is set, since query doesn't know which certificate it's
+// querying about
+func (config *Config) forCertificate(cert *x509.Certificate) *Config {
+	if config == nil || config.DomainLabels == nil {
+		return config
+	}
+	certConfig := new(Config)
+	*certConfig = *config
+	certConfig.domainNames = config.DomainLabels.labelAll(DomainNames(cert))
+	return certConfig
+}
+
+func (config *Config) queryDomainNames() []string {
+	if config != nil {
+		return config.domainNames
+	} else {
+		return nil
+	}
+}
+
+// Return the URL of the OCSP responder to query about cert, and the reason it
+// was chosen, or an empty URL if there is none
+func (config *Config) responderURL(cert *x509.Certificate, issuerCert *x509.Certificate) (string, string) {
+	if template := config.issuerOverride(issuerCert).ResponderURL; template != "" {
+		return expandResponderURL(template, cert, issuerCert), "issuer override"
+	}
+	servers := getOCSPServers(cert, config.allowHTTPS())
+	if len(servers) == 0 {
+		return "", ""
+	}
+	var selector *URLSelector
+	if config != nil {
+		selector = config.URLSelector
+	}
+	return selector.choose(servers)
+}
+
+func (config *Config) observeResponder(serverURL string, latency time.Duration, failed bool) {
+	if config != nil && config.URLSelector != nil {
+		config.URLSelector.observe(serverURL, latency, failed)
+	}
+}
+
+func (config *Config) waitForResponder(ctx context.Context, serverURL string) error {
+	if config != nil && config.rateLimiter != nil {
+		return config.rateLimiter.wait(ctx, serverURL)
+	} else {
+		return nil
+	}
+}
+
+func (config *Config) onQueryDone(info QueryInfo) {
+	if config != nil && config.OnQueryDone != nil {
+		config.OnQueryDone(info)
+	}
+}
+
+func (config *Config) prepareRequest(httpRequest *http.Request) error {
+	if config != nil && config.PrepareRequest != nil {
+		return config.PrepareRequest(httpRequest)
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNoCRL is returned when the certificate does not contain an HTTP CRL distribution point
+var ErrNoCRL = errors.New("Certificate does not contain an HTTP CRL distribution point")
+
+var (
+	oidCRLReason                = asn1.ObjectIdentifier{2, 5, 29, 21}
+	oidDeltaCRLIndicator        = asn1.ObjectIdentifier{2, 5, 29, 27}
+	oidIssuingDistributionPoint = asn1.ObjectIdentifier{2, 5, 29, 28}
+)
+
+// The Issuing Distribution Point CRL extension (Section 5.2.5 of RFC 5280)
+type issuingDistributionPoint struct {
+	DistributionPoint          distributionPointName `asn1:"optional,tag:0"`
+	OnlyContainsUserCerts      bool                  `asn1:"optional,tag:1"`
+	OnlyContainsCACerts        bool                  `asn1:"optional,tag:2"`
+	OnlySomeReasons            asn1.BitString        `asn1:"optional,tag:3"`
+	IndirectCRL                bool                  `asn1:"optional,tag:4"`
+	OnlyContainsAttributeCerts bool                  `asn1:"optional,tag:5"`
+}
+
+type distributionPointName struct {
+	FullName     []asn1.RawValue  `asn1:"optional,tag:0"`
+	RelativeName pkix.RDNSequence `asn1:"optional,tag:1"`
+}
+
+// Identifies the mechanism used to determine a certificate's revocation status
+type Mechanism string
+
+const (
+	MechanismOCSP Mechanism = "ocsp"
+	MechanismCRL  Mechanism = "crl"
+)
+
+// Represents the result of [CheckRevocation]
+type RevocationResult struct {
+	Revoked   bool
+	Info      RevocationInfo
+	Mechanism Mechanism // The mechanism which produced the result
+
+	// If Mechanism is MechanismCRL, the error which caused OCSP to be
+	// abandoned, such as [ErrNoResponder]
+	OCSPErr error
+}
+
+// Caches the CRLs downloaded and verified by [CheckCRL], keyed by URL and issuer,
+// until their nextUpdate time.  CRLs which lack a nextUpdate time are not cached.
+// The zero value is an empty cache ready to use.  A CRLCache is safe for concurrent use.
+type CRLCache struct {
+	// If non-zero, the maximum number of CRLs.  When the cache is full, the
+	// least recently used CRL is discarded.
+	MaxEntries int
+
+	crls cacheMap[crlCacheKey, *x509.RevocationList]
+}
+
+type crlCacheKey struct {
+	url     string
+	subject string
+	spki    string
+}
+
+func makeCRLCacheKey(crlURL string, issuerCert *x509.Certificate) crlCacheKey {
+	return crlCacheKey{url: crlURL, subject: string(issuerCert.RawSubject), spki: string(issuerCert.RawSubjectPublicKeyInfo)}
+}
+
+func (cache *CRLCache) get(key crlCacheKey) *x509.RevocationList {
+	crl, _ := cache.crls.get(key)
+	return crl
+}
+
+func (cache *CRLCache) put(key crlCacheKey, crl *x509.RevocationList) {
+	if crl.NextUpdate.IsZero() {
+		return
+	}
+	cache.crls.put(key, crl, int64(len(crl.Raw)), crl.NextUpdate, cache.MaxEntries)
+}
+
+// Return statistics about the cache, e.g. for exporting as metrics
+func (cache *CRLCache) Stats() CacheStats {
+	return cache.crls.statistics()
+}
+
+// Given a certificate and its issuer, download the certificate's CRL and return
+// if the certificate was revoked.  The CRL must be signed by issuerCert, and
+// its nextUpdate time must not have passed.  Delta CRLs, indirect CRLs, and CRLs
+// whose Issuing Distribution Point extension shows that they don't cover
+// every revocation of cert are rejected.  The download is limited to
+// [Config].MaxCRLSize bytes, and is subject to the same maximum timeout as [Query].
+// If [Config].CRLCache is non-nil, verified CRLs are cached in it.
+//
+// cert can be a precertificate, but issuerCert must be the final certificate's issuer,
+// not the precertificate's issuer.
+//
+// If config is nil, a zero-value [Config] is used, which provides
+// sensible defaults.
+//
+// Returns [ErrNoCRL] if the certificate lacks an "http://" CRL distribution point,
+// or an error if the CRL can't be downloaded, parsed, or verified.
+func CheckCRL(ctx context.Context, cert *x509.Certificate, issuerCert *x509.Certificate, config *Config) (revoked bool, info RevocationInfo, err error) {
+	err = ErrNoCRL
+	for _, crlURL := range cert.CRLDistributionPoints {
+		if !strings.HasPrefix(crlURL, "http://") {
+			continue
+		}
+		var crl *x509.RevocationList
+		crl, err = fetchCRL(ctx, crlURL, issuerCert, config)
+		if err == nil {
+			err = checkCRLScope(crl, crlURL, cert)
+		}
+		if err != nil {
+			err = fmt.Errorf("error with CRL %s: %w", crlURL, err)
+			continue
+		}
+		// RevokedCertificates is deprecated as of Go 1.21 in favor of
+		// RevokedCertificateEntries, but ParseRevocationList still populates it,
+		// and unlike RevokedCertificateEntries it's available in Go 1.19 and 1.20,
+		// which go.mod supports
+		for _, entry := range crl.RevokedCertificates {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				revoked = true
+				info.Time = entry.RevocationTime
+				info.Reason = crlReason(entry.Extensions)
+				break
+			}
+		}
+		return revoked, info, nil
+	}
+	return
+}
+
+// Given a certificate and its issuer, determine if the certificate was revoked
+// using OCSP, falling back to the certificate's CRL if the OCSP check fails
+// for any reason other than [ErrNoCheck] (e.g. because the certificate has no
+// OCSP responder, or the responder is unavailable).
+//
+// cert can be a precertificate, but issuerCert must be the final certificate's issuer,
+// not the precertificate's issuer.
+//
+// If config is nil, a zero-value [Config] is used, which provides
+// sensible defaults.
+//
+// This function is a wrapper around [CheckCert] and [CheckCRL].  If both mechanisms
+// fail, the error from CheckCRL is returned and result.OCSPErr contains the error
+// from CheckCert.
+func CheckRevocation(ctx context.Context, cert *x509.Certificate, issuerCert *x509.Certificate, config *Config) (result RevocationResult, err error) {
+	result.Revoked, result.Info, err = CheckCert(ctx, cert, issuerCert, config)
+	if err == nil || errors.Is(err, ErrNoCheck) {
+		result.Mechanism = MechanismOCSP
+		return
+	}
+
+	result.OCSPErr = err
+	result.Mechanism = MechanismCRL
+	result.Revoked, result.Info, err = CheckCRL(ctx, cert, issuerCert, config)
+	return
+}
+
+func fetchCRL(ctx context.Context, crlURL string, issuerCert *x509.Certificate, config *Config) (*x509.RevocationList, error) {
+	cache := config.crlCache()
+	if cache == nil {
+		return downloadCRL(ctx, crlURL, issuerCert, config)
+	}
+	key := makeCRLCacheKey(crlURL, issuerCert)
+	if crl := cache.get(key); crl != nil {
+		return crl, nil
+	}
+	crl, err := downloadCRL(ctx, crlURL, issuerCert, config)
+	if err != nil {
+		return nil, err
+	}
+	cache.put(key, crl)
+	return crl, nil
+}
+
+func downloadCRL(ctx context.Context, crlURL string, issuerCert *x509.Certificate, config *Config) (*x509.RevocationList, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.maxQueryTimeout())
+	defer cancel()
+
+	httpRequest, err := http.NewRequestWithContext(ctx, "GET", crlURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("User-Agent", config.userAgent())
+
+	httpResponse, err := config.httpClient().Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	maxSize := config.maxCRLSize()
+	body, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxSize+1))
+	httpResponse.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if httpResponse.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP error: %s", httpResponse.Status)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("CRL is larger than %d bytes", maxSize)
+	}
+
+	crl, err := x509.ParseRevocationList(body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse CRL: %w", err)
+	}
+	if !bytes.Equal(crl.RawIssuer, issuerCert.RawSubject) {
+		return nil, errors.New("CRL was not issued by the certificate's issuer")
+	}
+	if err := checkCRLSignature(crl, issuerCert); err != nil {
+		return nil, fmt.Errorf("bad CRL signature: %w", err)
+	}
+	for _, ext := range crl.Extensions {
+		if ext.Id.Equal(oidDeltaCRLIndicator) {
+			// A delta CRL lists only the changes since a base CRL
+			return nil, errors.New("CRL is a delta CRL")
+		}
+	}
+	if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		return nil, fmt.Errorf("CRL expired at %s", crl.NextUpdate)
+	}
+	return crl, nil
+}
+
+// Verify crl's signature.  If issuerCert was built by [ParseCertificate] from a
+// subject and public key, its public key algorithm is unknown, which
+// CheckSignatureFrom rejects, so the signature is checked using the key directly.
+func checkCRLSignature(crl *x509.RevocationList, issuerCert *x509.Certificate) error {
+	if issuerCert.PublicKeyAlgorithm == x509.UnknownPublicKeyAlgorithm {
+		return issuerCert.CheckSignature(crl.SignatureAlgorithm, crl.RawTBSRevocationList, crl.Signature)
+	}
+	return crl.CheckSignatureFrom(issuerCert)
+}
+
+// Return an error unless crl, downloaded from crlURL, covers every revocation
+// of cert, according to its Issuing Distribution Point extension
+func checkCRLScope(crl *x509.RevocationList, crlURL string, cert *x509.Certificate) error {
+	for _, ext := range crl.Extensions {
+		if !ext.Id.Equal(oidIssuingDistributionPoint) {
+			continue
+		}
+		var idp issuingDistributionPoint
+		if rest, err := asn1.Unmarshal(ext.Value, &idp); err != nil {
+			return fmt.Errorf("unable to parse CRL issuing distribution point: %w", err)
+		} else if len(rest) > 0 {
+			return errors.New("trailing data after CRL issuing distribution point")
+		}
+		switch {
+		case idp.DistributionPoint.FullName != nil && !containsURI(idp.DistributionPoint.FullName, crlURL):
+			return errors.New("CRL's issuing distribution point does not match the certificate's distribution point")
+		case idp.OnlyContainsUserCerts && cert.IsCA:
+			return errors.New("CRL covers only end-entity certificates, but the certificate is a CA")
+		case idp.OnlyContainsCACerts && !cert.IsCA:
+			return errors.New("CRL covers only CA certificates, but the certificate is not a CA")
+		case idp.OnlyContainsAttributeCerts:
+			return errors.New("CRL covers only attribute certificates")
+		case idp.OnlySomeReasons.BitLength > 0:
+			return errors.New("CRL covers only some revocation reasons")
+		case idp.IndirectCRL:
+			return errors.New("CRL is an indirect CRL")
+		}
+	}
+	return nil
+}
+
+// Report whether the GeneralNames contain the given uniformResourceIdentifier
+func containsURI(names []asn1.RawValue, uri string) bool {
+	for _, name := range names {
+		if name.Class == asn1.ClassContextSpecific && name.Tag == 6 && string(name.Bytes) == uri {
+			return true
+		}
+	}
+	return false
+}
+
+func crlReason(extensions []pkix.Extension) int {
+	for _, ext := range extensions {
+		if ext.Id.Equal(oidCRLReason) {
+			var reason asn1.Enumerated
+			if _, err := asn1.Unmarshal(ext.Value, &reason); err == nil {
+				return int(reason)
+			}
+		}
+	}
+	return 0
+}
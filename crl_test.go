@@ -0,0 +1,165 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/ocsputil/ocsptest"
+)
+
+// Issue a certificate from responder's CA whose CRL distribution point is served
+// by a test server, and return it along with the URL of its CRL.  serveCRL
+// creates the CRL served for each request.
+func newCRLTestCertificate(t *testing.T, responder *ocsptest.Responder, serveCRL func(crlURL string) []byte) (*x509.Certificate, string) {
+	t.Helper()
+	var crlURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write(serveCRL(crlURL))
+	}))
+	t.Cleanup(server.Close)
+	crlURL = server.URL + "/ca.crl"
+
+	cert, key, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := *cert
+	template.CRLDistributionPoints = []string{crlURL}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, responder.CA, key.Public(), responder.CAKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(certDER); err != nil {
+		t.Fatal(err)
+	}
+	return cert, crlURL
+}
+
+func createTestCRL(t *testing.T, responder *ocsptest.Responder, revoked *big.Int, extensions ...pkix.Extension) []byte {
+	t.Helper()
+	now := time.Now()
+	template := &x509.RevocationList{
+		Number:          big.NewInt(1),
+		ThisUpdate:      now.Add(-time.Hour),
+		NextUpdate:      now.Add(time.Hour),
+		ExtraExtensions: extensions,
+	}
+	if revoked != nil {
+		template.RevokedCertificates = []pkix.RevokedCertificate{{SerialNumber: revoked, RevocationTime: now.Add(-time.Hour)}}
+	}
+	crlDER, err := x509.CreateRevocationList(rand.Reader, template, responder.CA, responder.CAKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crlDER
+}
+
+func issuingDistributionPointExtension(t *testing.T, idp issuingDistributionPoint) pkix.Extension {
+	t.Helper()
+	value, err := asn1.Marshal(idp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oidIssuingDistributionPoint, Critical: true, Value: value}
+}
+
+func uriName(uri string) []asn1.RawValue {
+	return []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(uri)}}
+}
+
+func TestCheckCRLParsedIssuer(t *testing.T) {
+	responder := newTestResponder(t)
+	var revoked *big.Int
+	cert, _ := newCRLTestCertificate(t, responder, func(string) []byte {
+		return createTestCRL(t, responder, revoked)
+	})
+	revoked = cert.SerialNumber
+
+	// An issuer built from a subject and public key has no public key algorithm
+	cert, issuerCert, err := ParseCertificate(cert.Raw, responder.CA.RawSubject, responder.CA.RawSubjectPublicKeyInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isRevoked, _, err := CheckCRL(context.Background(), cert, issuerCert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isRevoked {
+		t.Errorf("CheckCRL reported a revoked certificate as not revoked")
+	}
+}
+
+func TestCheckCRLScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		extension func(crlURL string) pkix.Extension
+		wantErr   bool
+	}{
+		{"matching distribution point", func(crlURL string) pkix.Extension {
+			return issuingDistributionPointExtension(t, issuingDistributionPoint{DistributionPoint: distributionPointName{FullName: uriName(crlURL)}, OnlyContainsUserCerts: true})
+		}, false},
+		{"other distribution point", func(crlURL string) pkix.Extension {
+			return issuingDistributionPointExtension(t, issuingDistributionPoint{DistributionPoint: distributionPointName{FullName: uriName("http://crl.example.com/other.crl")}})
+		}, true},
+		{"only CA certificates", func(string) pkix.Extension {
+			return issuingDistributionPointExtension(t, issuingDistributionPoint{OnlyContainsCACerts: true})
+		}, true},
+		{"only some reasons", func(string) pkix.Extension {
+			return issuingDistributionPointExtension(t, issuingDistributionPoint{OnlySomeReasons: asn1.BitString{Bytes: []byte{0x40}, BitLength: 2}})
+		}, true},
+		{"indirect", func(string) pkix.Extension {
+			return issuingDistributionPointExtension(t, issuingDistributionPoint{IndirectCRL: true})
+		}, true},
+		{"delta", func(string) pkix.Extension {
+			value, _ := asn1.Marshal(1)
+			return pkix.Extension{Id: oidDeltaCRLIndicator, Critical: true, Value: value}
+		}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			responder := newTestResponder(t)
+			cert, _ := newCRLTestCertificate(t, responder, func(crlURL string) []byte {
+				return createTestCRL(t, responder, nil, test.extension(crlURL))
+			})
+			_, _, err := CheckCRL(context.Background(), cert, responder.CA, nil)
+			if test.wantErr && err == nil {
+				t.Errorf("CheckCRL succeeded; want an error")
+			} else if !test.wantErr && err != nil {
+				t.Errorf("CheckCRL failed: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"crypto/x509"
+	"strings"
+	"sync"
+)
+
+// The label which [DomainLabels] uses in place of names beyond its limit
+const OtherDomain = "other"
+
+// Limits the number of distinct domain names which appear in [QueryInfo].DomainNames,
+// so that metrics labeled by domain name have bounded cardinality.  The first Max
+// distinct names are passed through unchanged, and later names are replaced with
+// [OtherDomain].
+//
+// The zero value is ready to use.  A DomainLabels is safe for concurrent use.
+type DomainLabels struct {
+	// The maximum number of distinct names.  If zero, 1000 is used.
+	Max int
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func (labels *DomainLabels) max() int {
+	if labels.Max != 0 {
+		return labels.Max
+	} else {
+		return 1000
+	}
+}
+
+// Return name if it has been seen before or the limit has not been reached,
+// and [OtherDomain] otherwise
+func (labels *DomainLabels) Label(name string) string {
+	labels.mu.Lock()
+	defer labels.mu.Unlock()
+	if _, ok := labels.seen[name]; ok {
+		return name
+	}
+	if len(labels.seen) >= labels.max() {
+		return OtherDomain
+	}
+	if labels.seen == nil {
+		labels.seen = make(map[string]struct{})
+	}
+	labels.seen[name] = struct{}{}
+	return name
+}
+
+// Return the labels for the given names, without duplicates
+func (labels *DomainLabels) labelAll(names []string) []string {
+	var result []string
+	for _, name := range names {
+		label := labels.Label(name)
+		if !containsString(result, label) {
+			result = append(result, label)
+		}
+	}
+	return result
+}
+
+// Return the domain names of cert: its DNS subject alternative names, followed
+// by its subject common name if it's not among them.  Names are lowercased.
+func DomainNames(cert *x509.Certificate) []string {
+	var names []string
+	for _, name := range cert.DNSNames {
+		if name = strings.ToLower(name); !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	if cn := strings.ToLower(cert.Subject.CommonName); cn != "" && !containsString(names, cn) {
+		names = append(names, cn)
+	}
+	return names
+}
+
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
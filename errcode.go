@@ -0,0 +1,177 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// A short, stable name classifying an error returned by this package, such as
+// "http_status".  Unlike error messages, which may be reworded, error codes
+// never change once defined, so they are suitable for alerting rules and for
+// programs which parse the output of evalocsp.
+type ErrorCode string
+
+const (
+	ErrorCodeNone               ErrorCode = ""                      // The error is nil
+	ErrorCodeOther              ErrorCode = "other"                 // The error does not fall into any other category
+	ErrorCodeCertificateInvalid ErrorCode = "certificate_invalid"   // The certificate or issuer public key could not be parsed
+	ErrorCodeNoResponder        ErrorCode = "no_responder"          // [ErrNoResponder]
+	ErrorCodeNoCheck            ErrorCode = "no_check"              // [ErrNoCheck]
+	ErrorCodeNoIssuerURL        ErrorCode = "no_issuer_url"         // [ErrNoIssuerURL]
+	ErrorCodeIssuerFetch        ErrorCode = "issuer_fetch"          // The issuer could not be downloaded by [FetchIssuer]
+	ErrorCodePhaseTimeout       ErrorCode = "phase_timeout"         // [ErrPhaseTimeout]
+	ErrorCodeTimeout            ErrorCode = "timeout"               // The context deadline or an HTTP timeout was exceeded
+	ErrorCodeCanceled           ErrorCode = "canceled"              // The context was canceled
+	ErrorCodeDNS                ErrorCode = "dns"                   // The responder's hostname could not be resolved
+	ErrorCodeConnection         ErrorCode = "connection"            // The HTTP request to the responder failed
+	ErrorCodeRead               ErrorCode = "read"                  // The responder's HTTP response body could not be read
+	ErrorCodeHTTPStatus         ErrorCode = "http_status"           // The responder returned an HTTP status other than 200
+	ErrorCodeContentType        ErrorCode = "content_type"          // The responder returned the wrong Content-Type
+	ErrorCodeResponderStatus    ErrorCode = "responder_status"      // The responder returned an unsuccessful OCSP response status, such as tryLater
+	ErrorCodeResponseInvalid    ErrorCode = "response_invalid"      // The OCSP response is malformed, has a bad signature, or is for a different certificate
+	ErrorCodeResponseSHA1       ErrorCode = "response_sha1"         // The OCSP response is signed using SHA-1, which the [Profile] prohibits
+	ErrorCodeNonceMismatch      ErrorCode = "nonce_mismatch"        // [ErrNonceMismatch]
+	ErrorCodeCertIDHashMismatch ErrorCode = "cert_id_hash_mismatch" // [ErrCertIDHashMismatch]
+	ErrorCodeUnknown            ErrorCode = "unknown"               // [ErrUnknown]
+	ErrorCodeChainRevoked       ErrorCode = "chain_revoked"         // [ErrChainRevoked]
+	ErrorCodeChainUnchecked     ErrorCode = "chain_unchecked"       // [ErrChainUnchecked]
+)
+
+// Describes an [ErrorCode], as listed in [ErrorCodes]
+type ErrorCodeInfo struct {
+	Code        ErrorCode `json:"code"`
+	Description string    `json:"description"`
+}
+
+// The catalog of every [ErrorCode] which [ErrorCodeOf] can return, other than
+// [ErrorCodeNone].  Codes may be added in future versions, so programs should
+// treat unrecognized codes like [ErrorCodeOther].
+var ErrorCodes = []ErrorCodeInfo{
+	{ErrorCodeOther, "The error does not fall into any other category"},
+	{ErrorCodeCertificateInvalid, "The certificate or issuer public key could not be parsed"},
+	{ErrorCodeNoResponder, "The certificate does not contain an OCSP responder URL"},
+	{ErrorCodeNoCheck, "The certificate is an OCSP responder certificate with the OCSP No Check extension"},
+	{ErrorCodeNoIssuerURL, "The certificate does not contain a caIssuers URL"},
+	{ErrorCodeIssuerFetch, "The certificate's issuer could not be downloaded"},
+	{ErrorCodePhaseTimeout, "A phase of the OCSP check exceeded its timeout"},
+	{ErrorCodeTimeout, "The OCSP check timed out"},
+	{ErrorCodeCanceled, "The OCSP check was canceled"},
+	{ErrorCodeDNS, "The OCSP responder's hostname could not be resolved"},
+	{ErrorCodeConnection, "The HTTP request to the OCSP responder failed"},
+	{ErrorCodeRead, "The OCSP responder's HTTP response could not be read"},
+	{ErrorCodeHTTPStatus, "The OCSP responder returned an HTTP status other than 200"},
+	{ErrorCodeContentType, "The OCSP responder returned a Content-Type other than application/ocsp-response"},
+	{ErrorCodeResponderStatus, "The OCSP responder returned an unsuccessful response status, such as tryLater"},
+	{ErrorCodeResponseInvalid, "The OCSP response is malformed, has a bad signature, or is for a different certificate"},
+	{ErrorCodeResponseSHA1, "The OCSP response is signed using SHA-1"},
+	{ErrorCodeNonceMismatch, "The OCSP response nonce does not match the request nonce"},
+	{ErrorCodeCertIDHashMismatch, "The OCSP response CertID uses a different hash algorithm than the request"},
+	{ErrorCodeUnknown, "The OCSP responder does not know the certificate"},
+	{ErrorCodeChainRevoked, "A certificate in the chain is revoked"},
+	{ErrorCodeChainUnchecked, "A certificate in the chain could not be checked"},
+}
+
+// An error annotated with an ErrorCode.  Error returns the underlying error's
+// message unchanged.
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+func withCode(code ErrorCode, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// Return the [ErrorCode] classifying err, which should have been returned by
+// a function in this package.  Returns [ErrorCodeNone] if err is nil, or
+// [ErrorCodeOther] if err doesn't fall into a more specific category.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ErrorCodeNone
+	}
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	switch {
+	case errors.Is(err, ErrChainRevoked):
+		return ErrorCodeChainRevoked
+	case errors.Is(err, ErrChainUnchecked):
+		return ErrorCodeChainUnchecked
+	case errors.Is(err, ErrNoResponder):
+		return ErrorCodeNoResponder
+	case errors.Is(err, ErrNoCheck):
+		return ErrorCodeNoCheck
+	case errors.Is(err, ErrNoIssuerURL):
+		return ErrorCodeNoIssuerURL
+	case errors.Is(err, ErrPhaseTimeout):
+		return ErrorCodePhaseTimeout
+	case errors.Is(err, ErrNonceMismatch):
+		return ErrorCodeNonceMismatch
+	case errors.Is(err, ErrCertIDHashMismatch):
+		return ErrorCodeCertIDHashMismatch
+	case errors.Is(err, ErrUnknown):
+		return ErrorCodeUnknown
+	case errors.Is(err, context.Canceled):
+		return ErrorCodeCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorCodeTimeout
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorCodeDNS
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorCodeTimeout
+	}
+	return ErrorCodeOther
+}
+
+// Return the ErrorCode for an error from ocsp.ParseResponseForCert
+func parseErrorCode(err error) ErrorCode {
+	var responseErr ocsp.ResponseError
+	if errors.As(err, &responseErr) {
+		return ErrorCodeResponderStatus
+	}
+	return ErrorCodeResponseInvalid
+}
+
+// Return the ErrorCode for an error from an HTTP client
+func transportErrorCode(err error) ErrorCode {
+	if code := ErrorCodeOf(err); code != ErrorCodeOther {
+		return code
+	}
+	return ErrorCodeConnection
+}
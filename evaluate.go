@@ -27,18 +27,36 @@ package ocsputil
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
 	"time"
 )
 
 // Represents the result of [Evaluate].  If Err is nil, then the other fields are non-nil.
 // If Err is non-nil, then any of the other fields may be nil, depending on the nature
-// of the error.
+// of the error.  The exception is Vantage, which is always copied from [Config].Vantage.
+//
+// If the response was reused from [Config].Cache, then Response.FromCache is true,
+// Method and TLS are nil, and ResponseTime, Timings, and Attempts are zero.
+//
+// RequestBytes and ResponseBytes are nil if discarded due to [Config].RetainBodies.
 type Evaluation struct {
-	ResponderURL  *string
-	RequestBytes  []byte
-	ResponseBytes []byte
-	ResponseTime  time.Duration
-	Err           error
+	ResponderURL       *string
+	ResponderURLReason string // Why ResponderURL was chosen, such as "only URL" or "lowest latency" (see [URLSelector])
+	RequestBytes       []byte
+	Method             *string // The HTTP method which was used to send the request ("GET" or "POST")
+	ResponseBytes      []byte
+	ResponseHash       []byte        // SHA-256 hash of ResponseBytes, for detecting changed responses
+	ResponseTime       time.Duration // How long the responder took to respond to the final attempt
+	Timings            Timings       // Breakdown of the HTTP request which produced the response
+	Attempts           int           // The number of attempts made to query the responder (see [Config].Retries)
+	TLS                *TLSInfo      // The negotiated TLS parameters, or nil if the responder isn't https (see [Config].AllowHTTPS)
+	Response           *ResponseDetails
+	Findings           []Finding // Populated only if [Config].Lint or [Config].ExpiryWarning is set
+	Vantage            map[string]string
+	DomainNames        []string // The certificate's domain names, as returned by [DomainNames]
+	Err                error    // Classified by [ErrorCodeOf]
 }
 
 // Given a certificate, its issuer's subject, and its issuer's public key,
@@ -48,7 +66,7 @@ type Evaluation struct {
 // from the final certificate's issuer, not the precertificate's issuer.
 //
 // This function is a wrapper around [ParseCertificate], [CreateRequest], [Query],
-// and [CheckResponse].  See the documentation for those functions for details
+// and [CheckResponseDetails].  See the documentation for those functions for details
 // about the behavior.
 //
 // If config is nil, a zero-value [Config] is used, which provides
@@ -58,40 +76,138 @@ type Evaluation struct {
 //
 // [OCSP Watch]: https://sslmate.com/labs/ocsp_watch
 func Evaluate(ctx context.Context, certData []byte, issuerSubject []byte, issuerPubkey []byte, config *Config) (eval Evaluation) {
+	eval.Vantage = config.vantage()
+	defer func() {
+		if !config.retainBodies(eval.Err) {
+			eval.RequestBytes = nil
+			eval.ResponseBytes = nil
+		}
+	}()
+
+	parseStart := time.Now()
 	cert, issuerCert, err := ParseCertificate(certData, issuerSubject, issuerPubkey)
 	if err != nil {
 		eval.Err = err
 		return
 	}
+	if err := checkPhaseTimeout("parsing", parseStart, config.parseTimeout()); err != nil {
+		eval.Err = err
+		return
+	}
+
+	config = config.forIssuer(issuerCert).forCertificate(cert)
+	eval.DomainNames = DomainNames(cert)
 
-	serverURL, requestBytes, err := CreateRequest(cert, issuerCert)
+	if finding := expiryFinding(cert, config.expiryWarning(), time.Now()); finding != nil {
+		eval.Findings = append(eval.Findings, *finding)
+	}
+
+	serverURL, urlReason := config.responderURL(cert, issuerCert)
+	requestBytes, err := createRequestFor(cert, issuerCert, serverURL, config)
 	if err != nil {
 		eval.Err = err
 		return
 	}
 	eval.ResponderURL = &serverURL
+	eval.ResponderURLReason = urlReason
 	eval.RequestBytes = requestBytes
 
-	responseBytes, responseTime, err := timedQuery(ctx, serverURL, requestBytes, config)
-	if err != nil {
-		eval.Err = err
-		return
+	responseBytes, details := config.cachedResponse(cert, issuerCert)
+	if details == nil {
+		if err := config.waitForResponder(ctx, serverURL); err != nil {
+			eval.Err = err
+			return
+		}
+		result, err := query(ctx, serverURL, requestBytes, config)
+		if result.method != "" {
+			eval.Method = &result.method
+		}
+		eval.Timings = result.timings
+		eval.Attempts = result.attempts
+		eval.TLS = result.tls
+		if err != nil {
+			eval.Err = err
+			return
+		}
+		responseBytes = result.responseBytes
+		eval.ResponseHash = result.responseHash
+		eval.ResponseTime = result.responseTime
+	} else {
+		responseHash := sha256.Sum256(responseBytes)
+		eval.ResponseHash = responseHash[:]
 	}
 	eval.ResponseBytes = responseBytes
-	eval.ResponseTime = responseTime
 
-	if _, _, err := CheckResponse(cert, issuerCert, responseBytes); err != nil {
-		eval.Err = err
-		return
+	if config.lint() {
+		eval.Findings = append(eval.Findings, lintFindings(cert, issuerCert, responseBytes, config)...)
+		if finding := tlsFinding(eval.TLS); finding != nil {
+			eval.Findings = append(eval.Findings, *finding)
+		}
+	}
+
+	if details == nil {
+		verifyStart := time.Now()
+		details, err = checkResponseDetails(cert, issuerCert, responseBytes, config)
+		if err != nil {
+			eval.Response = details
+			eval.Err = err
+			return
+		}
+		if err := checkPhaseTimeout("verification", verifyStart, config.verifyTimeout()); err != nil {
+			eval.Response = details
+			eval.Err = err
+			return
+		}
+		config.observeSkew(serverURL, details)
+		config.cacheResponse(cert, issuerCert, responseBytes, details)
+	}
+	if config.lint() {
+		if finding := certIDHashFinding(config.certIDHash(issuerCert), details); finding != nil {
+			eval.Findings = append(eval.Findings, *finding)
+		}
 	}
+	eval.Response = details
 
 	return
 }
 
-func timedQuery(ctx context.Context, serverURL string, requestBytes []byte, config *Config) ([]byte, time.Duration, error) {
-	startTime := time.Now()
-	responseBytes, err := Query(ctx, serverURL, requestBytes, config)
-	responseTime := time.Since(startTime)
+func checkPhaseTimeout(phase string, startTime time.Time, timeout time.Duration) error {
+	if elapsed := time.Since(startTime); timeout != 0 && elapsed > timeout {
+		return fmt.Errorf("%w: %s took %s (limit %s)", ErrPhaseTimeout, phase, elapsed, timeout)
+	}
+	return nil
+}
 
-	return responseBytes, responseTime, err
+// The result of evaluating a certificate against one of several candidate issuers,
+// as returned by [EvaluateCandidates]
+type CandidateEvaluation struct {
+	Issuer     *x509.Certificate
+	Evaluation Evaluation
+}
+
+// Evaluate the certificate's OCSP responder once for each candidate issuer, such
+// as the cross-signed variants of an intermediate, and return the results in the
+// same order as candidates.  This reveals responders which only produce valid
+// responses for some variants.  Candidates with the same subject and public key
+// produce identical OCSP requests, so the responder is queried only once for them.
+//
+// If config is nil, a zero-value [Config] is used, which provides
+// sensible defaults.
+func EvaluateCandidates(ctx context.Context, certData []byte, candidates []*x509.Certificate, config *Config) []CandidateEvaluation {
+	type issuerKey struct {
+		subject string
+		pubkey  string
+	}
+	results := make([]CandidateEvaluation, len(candidates))
+	evaluated := make(map[issuerKey]Evaluation)
+	for i, candidate := range candidates {
+		key := issuerKey{string(candidate.RawSubject), string(candidate.RawSubjectPublicKeyInfo)}
+		eval, ok := evaluated[key]
+		if !ok {
+			eval = Evaluate(ctx, certData, candidate.RawSubject, candidate.RawSubjectPublicKeyInfo, config)
+			evaluated[key] = eval
+		}
+		results[i] = CandidateEvaluation{Issuer: candidate, Evaluation: eval}
+	}
+	return results
 }
@@ -0,0 +1,186 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// The result of querying one of a responder's IP addresses, as part of a [FailoverReport]
+type AddressResult struct {
+	IP           string        `json:"ip"`
+	OK           bool          `json:"ok"`
+	Error        string        `json:"error,omitempty"`
+	ResponseTime time.Duration `json:"response_time"`
+}
+
+// A report of an OCSP responder's redundancy, as produced by [ProbeFailover]
+type FailoverReport struct {
+	Host      string          `json:"host"`
+	Addresses []AddressResult `json:"addresses"` // One per IP address the host resolves to
+
+	// The number of addresses which returned a valid response
+	Working int `json:"working"`
+
+	// The number of distinct networks (/24 for IPv4, /48 for IPv6) containing a
+	// working address, which is a rough indication of independent failure domains
+	WorkingNetworks int `json:"working_networks"`
+}
+
+// Report whether the responder remains reachable if any single IP address fails
+func (report *FailoverReport) Redundant() bool {
+	return report.Working >= 2
+}
+
+// Resolve the hostname of the OCSP responder for the given certificate, and
+// query each of its IP addresses in turn, with every other address excluded.
+// The result estimates the responder's real redundancy, as opposed to the
+// redundancy implied by its advertised DNS records.
+//
+// Queries are sent using POST, bypassing any HTTP proxy.  If config.HTTPClient is
+// non-nil, its transport must be an [*http.Transport], which is cloned for each address.  If config is
+// nil, a zero-value [Config] is used, which provides sensible defaults.
+//
+// Returns an error if cert has no responder URL, the hostname can't be resolved,
+// or ctx is done.  Failures of individual addresses are recorded in the report.
+func ProbeFailover(ctx context.Context, cert *x509.Certificate, issuerCert *x509.Certificate, config *Config) (*FailoverReport, error) {
+	config = config.forIssuer(issuerCert)
+	serverURL, _ := config.responderURL(cert, issuerCert)
+	if serverURL == "" {
+		return nil, ErrNoResponder
+	}
+	parsedURL, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	host := parsedURL.Hostname()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FailoverReport{Host: host, Addresses: make([]AddressResult, 0, len(addrs))}
+	networks := make(map[string]bool)
+	for _, addr := range addrs {
+		result := AddressResult{IP: addr.IP.String()}
+
+		pinnedConfig := new(Config)
+		if config != nil {
+			*pinnedConfig = *config
+		}
+		pinnedConfig.ResponderIPOverride = nil
+		pinnedConfig.pinnedClient = nil
+		pinnedConfig.HTTPClient, err = pinnedClient(config.httpClient(), addr.IP)
+		if err != nil {
+			return nil, err
+		}
+		p := &prober{ctx: ctx, serverURL: serverURL, issuerCert: issuerCert, config: pinnedConfig}
+		queryResult, _, err := p.probe(cert, MethodPOST, crypto.SHA1, false)
+		pinnedConfig.HTTPClient.CloseIdleConnections()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result.ResponseTime = queryResult.responseTime
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.OK = true
+			report.Working++
+			networks[networkOf(addr.IP)] = true
+		}
+		report.Addresses = append(report.Addresses, result)
+	}
+	report.WorkingNetworks = len(networks)
+	return report, nil
+}
+
+var errUnpinnableTransport = errors.New("HTTP transport is not an *http.Transport, so it can't be pinned to an IP address")
+
+// Return an HTTP client which behaves like client, but connects only to ip,
+// regardless of the hostname in the request URL.  The client has its own
+// transport, whose idle connections should be closed once it's no longer needed.
+func pinnedClient(client *http.Client, ip net.IP) (*http.Client, error) {
+	roundTripper := client.Transport
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+	t, ok := roundTripper.(*http.Transport)
+	if !ok {
+		return nil, errUnpinnableTransport
+	}
+	transport := t.Clone()
+
+	pinAddress := func(address string) (string, error) {
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return "", err
+		}
+		return net.JoinHostPort(ip.String(), port), nil
+	}
+	dial := transport.DialContext
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		dial = dialer.DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		pinnedAddress, err := pinAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		return dial(ctx, network, pinnedAddress)
+	}
+	if dialTLS := transport.DialTLSContext; dialTLS != nil {
+		transport.DialTLSContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			pinnedAddress, err := pinAddress(address)
+			if err != nil {
+				return nil, err
+			}
+			return dialTLS(ctx, network, pinnedAddress)
+		}
+	}
+	transport.Proxy = nil
+
+	pinned := *client
+	pinned.Transport = transport
+	return &pinned, nil
+}
+
+// Return the /24 (IPv4) or /48 (IPv6) network containing ip
+func networkOf(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
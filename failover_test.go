@@ -0,0 +1,66 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestPinnedClient(t *testing.T) {
+	responder := newTestResponder(t)
+	responderURL, err := url.Parse(responder.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hostname doesn't resolve, so the request succeeds only if it's pinned
+	client, err := pinnedClient(new(http.Client), net.IPv4(127, 0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get("http://ocsp.example.invalid:" + responderURL.Port() + "/issuer.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("pinned request returned HTTP status %d", resp.StatusCode)
+	}
+}
+
+func TestPinnedClientWrappedDefaultTransport(t *testing.T) {
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = wrappedTransport{defaultTransport}
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	if _, err := pinnedClient(new(http.Client), net.IPv4(127, 0, 0, 1)); err != errUnpinnableTransport {
+		t.Errorf("pinnedClient returned %v; want errUnpinnableTransport", err)
+	}
+}
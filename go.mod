@@ -1,5 +1,8 @@
 module software.sslmate.com/src/ocsputil
 
-go 1.17
+go 1.19
 
-require golang.org/x/crypto v0.0.0-20220321153916-2c7772ba3064 // indirect
+require (
+	golang.org/x/crypto v0.11.0
+	software.sslmate.com/src/go-pkcs12 v0.5.0
+)
@@ -42,7 +42,26 @@ import (
 // This function is a wrapper around [CreateRequest], [Query], and [CheckResponse].
 // See those functions' documentation for details about the behavior.
 func CheckCert(ctx context.Context, cert *x509.Certificate, issuerCert *x509.Certificate, config *Config) (revoked bool, info RevocationInfo, err error) {
-	serverURL, requestBytes, err := CreateRequest(cert, issuerCert)
+	details, err := CheckCertDetails(ctx, cert, issuerCert, config)
+	if err != nil {
+		return
+	}
+	return details.Revoked(), details.RevocationInfo, nil
+}
+
+// Like [CheckCert], but return the details of the OCSP response, including
+// whether it was reused from [Config].Cache.  details is non-nil if a response
+// was obtained and could be parsed, even if err is non-nil.
+//
+// This function is a wrapper around [CreateRequest], [Query], and [CheckResponseDetails].
+// See those functions' documentation for details about the behavior.
+func CheckCertDetails(ctx context.Context, cert *x509.Certificate, issuerCert *x509.Certificate, config *Config) (details *ResponseDetails, err error) {
+	config = config.forIssuer(issuerCert).forCertificate(cert)
+
+	if _, details := config.cachedResponse(cert, issuerCert); details != nil {
+		return details, nil
+	}
+	serverURL, requestBytes, err := createRequest(cert, issuerCert, config)
 	if err != nil {
 		return
 	}
@@ -50,7 +69,13 @@ func CheckCert(ctx context.Context, cert *x509.Certificate, issuerCert *x509.Cer
 	if err != nil {
 		return
 	}
-	return CheckResponse(cert, issuerCert, responseBytes)
+	details, err = checkResponseDetails(cert, issuerCert, responseBytes, config)
+	if err != nil {
+		return
+	}
+	config.observeSkew(serverURL, details)
+	config.cacheResponse(cert, issuerCert, responseBytes, details)
+	return
 }
 
 // Given a certificate, its issuer's subject, and its issuer's public key, perform
@@ -0,0 +1,96 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Configuration which applies only to certificates issued by a particular issuer,
+// for CAs with idiosyncrasies that a single global [Config] can't express.
+// See [Config].IssuerOverrides.
+type IssuerOverride struct {
+	// A template for the OCSP responder URL, used instead of the URL in the
+	// certificate's Authority Information Access extension.  This is useful for
+	// private PKIs whose AIA URLs are wrong or absent.  The following variables
+	// are substituted:
+	//
+	//   {serial}             hex-encoded serial number of the certificate
+	//   {issuer_key_hash}    hex-encoded SHA-1 hash of the issuer's public key, as used in OCSP CertIDs
+	//   {issuer_name_hash}   hex-encoded SHA-1 hash of the issuer's DER-encoded subject, as used in OCSP CertIDs
+	//   {issuer_id}          the issuer ID, as returned by [IssuerID]
+	//
+	// Unlike AIA URLs, the template may use "https://".  If empty, the AIA URL is used.
+	ResponderURL string
+
+	// The timeout for queries, used instead of [QueryTimeout] or [Config].AdaptiveTimeout.
+	// If zero, the timeout is not overridden.
+	Timeout time.Duration
+
+	// The hash algorithm used to compute the CertID in OCSP requests.  If zero, SHA-1 is used.
+	Hash crypto.Hash
+
+	// The HTTP method used to send OCSP requests, used instead of [Config].Method.
+	// If nil, the method is not overridden.
+	Method *Method
+}
+
+// Return a string which identifies an issuer, for use as a key in [Config].IssuerOverrides.
+// The issuer ID is the hex-encoded SHA-256 hash of the issuer's SubjectPublicKeyInfo.
+func IssuerID(issuerCert *x509.Certificate) string {
+	hash := sha256.Sum256(issuerCert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(hash[:])
+}
+
+func issuerKeyHash(issuerCert *x509.Certificate) string {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuerCert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return ""
+	}
+	hash := sha1.Sum(spki.PublicKey.RightAlign())
+	return hex.EncodeToString(hash[:])
+}
+
+func expandResponderURL(template string, cert *x509.Certificate, issuerCert *x509.Certificate) string {
+	issuerNameHash := sha1.Sum(issuerCert.RawSubject)
+	return strings.NewReplacer(
+		"{serial}", cert.SerialNumber.Text(16),
+		"{issuer_key_hash}", issuerKeyHash(issuerCert),
+		"{issuer_name_hash}", hex.EncodeToString(issuerNameHash[:]),
+		"{issuer_id}", IssuerID(issuerCert),
+	).Replace(template)
+}
@@ -0,0 +1,319 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"golang.org/x/crypto/ocsp"
+	"time"
+)
+
+// The severity of a [Finding]
+type Severity int
+
+const (
+	// The response is unusual but not prohibited
+	SeverityNotice Severity = iota
+
+	// The response is discouraged by the Baseline Requirements or RFC 6960
+	SeverityWarning
+
+	// The response violates the Baseline Requirements or RFC 6960
+	SeverityError
+)
+
+func (severity Severity) String() string {
+	switch severity {
+	case SeverityNotice:
+		return "notice"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(severity))
+	}
+}
+
+func (severity Severity) MarshalText() ([]byte, error) {
+	return []byte(severity.String()), nil
+}
+
+// A problem with an OCSP response detected by [Lint]
+type Finding struct {
+	Lint     string   `json:"lint"` // A short, stable name identifying the lint, such as "validity_too_long"
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+var oidCTPoison = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+
+type lintInput struct {
+	cert       *x509.Certificate
+	issuerCert *x509.Certificate
+	response   *ocsp.Response
+	profile    Profile
+	now        time.Time
+}
+
+// Describes a lint, as listed in [Lints]
+type LintInfo struct {
+	Name        string   `json:"name"` // The value of [Finding].Lint
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+}
+
+type lint struct {
+	LintInfo
+	check func(*lintInput) string // returns a non-empty message if the lint fails
+}
+
+var lints = []lint{
+	{LintInfo{"signature_invalid", SeverityError, "The response signature is not valid for the issuer"}, lintSignature},
+	{LintInfo{"sha1_signature", SeverityError, "The response is signed using SHA-1, which the Baseline Requirements prohibit"}, lintSHA1},
+	{LintInfo{"next_update_missing", SeverityError, "The response lacks nextUpdate, which the Baseline Requirements require"}, lintNextUpdateMissing},
+	{LintInfo{"next_update_before_this_update", SeverityError, "The response's nextUpdate is before its thisUpdate"}, lintNextUpdateOrder},
+	{LintInfo{"validity_too_long", SeverityError, "The response's validity interval exceeds the maximum allowed by the Baseline Requirements"}, lintValidity},
+	{LintInfo{"this_update_in_future", SeverityError, "The response's thisUpdate is in the future"}, lintThisUpdateInFuture},
+	{LintInfo{"response_expired", SeverityError, "The response's nextUpdate is in the past"}, lintExpired},
+	{LintInfo{"responder_cert_not_issued_by_ca", SeverityError, "The delegated responder certificate was not issued by the certificate's issuer"}, lintResponderIssuer},
+	{LintInfo{"responder_cert_missing_ocsp_signing", SeverityError, "The delegated responder certificate lacks the id-kp-OCSPSigning extended key usage"}, lintResponderEKU},
+	{LintInfo{"responder_cert_missing_nocheck", SeverityError, "The delegated responder certificate lacks the id-pkix-ocsp-nocheck extension"}, lintResponderNoCheck},
+	{LintInfo{"responder_cert_expired", SeverityError, "The delegated responder certificate was not valid when the response was produced"}, lintResponderExpired},
+	{LintInfo{"precert_status_unknown", SeverityError, "The responder reports unknown for a serial number which was used in a precertificate"}, lintPrecertUnknown},
+}
+
+// Lints which are checked outside of [Lint]
+var (
+	lintCertificateExpiring    = LintInfo{"certificate_expiring", SeverityWarning, "The certificate expires within the configured expiry warning window, or has expired"}
+	lintCertIDHashMismatch     = LintInfo{"cert_id_hash_mismatch", SeverityNotice, "The response CertID uses a different hash algorithm than the request"}
+	lintResponderTLSDeprecated = LintInfo{"responder_tls_deprecated", SeverityWarning, "The https:// responder negotiated a deprecated TLS version or cipher suite"}
+	lintFailed                 = LintInfo{"lint_failed", SeverityError, "The response could not be parsed, or does not pertain to the certificate, so the other lints were not checked"}
+)
+
+// The catalog of every lint which can appear in [Finding].Lint, for displaying
+// findings with an explanation.  Lints may be added in future versions.
+var Lints = lintCatalog()
+
+func lintCatalog() []LintInfo {
+	catalog := make([]LintInfo, 0, len(lints)+4)
+	for _, l := range lints {
+		catalog = append(catalog, l.LintInfo)
+	}
+	return append(catalog, lintCertificateExpiring, lintCertIDHashMismatch, lintResponderTLSDeprecated, lintFailed)
+}
+
+// Return a finding for the given lint
+func (info LintInfo) finding(message string) Finding {
+	return Finding{Lint: info.Name, Severity: info.Severity, Message: message}
+}
+
+// Return a finding if cert expires within window of now (or has already expired),
+// or nil otherwise.  Returns nil if window is zero.
+func expiryFinding(cert *x509.Certificate, window time.Duration, now time.Time) *Finding {
+	if window == 0 || cert.NotAfter.Sub(now) > window {
+		return nil
+	}
+	var message string
+	if now.After(cert.NotAfter) {
+		message = fmt.Sprintf("Certificate expired at %s", cert.NotAfter.UTC().Format(time.RFC3339))
+	} else {
+		message = fmt.Sprintf("Certificate expires at %s, in %s", cert.NotAfter.UTC().Format(time.RFC3339), cert.NotAfter.Sub(now).Round(time.Minute))
+	}
+	finding := lintCertificateExpiring.finding(message)
+	return &finding
+}
+
+// Return a finding if the response's CertID was computed using a different
+// hash algorithm than the request's, or nil otherwise
+func certIDHashFinding(requested crypto.Hash, details *ResponseDetails) *Finding {
+	if details.IssuerHash == requested {
+		return nil
+	}
+	finding := lintCertIDHashMismatch.finding(fmt.Sprintf("Response CertID uses %s, but the request used %s", details.IssuerHash, requested))
+	return &finding
+}
+
+// Given a certificate, its issuer, and an OCSP response, check the response for
+// violations of the Baseline Requirements and RFC 6960, and return the findings.
+// Unlike [CheckResponse], Lint does not stop at the first problem, and flags problems
+// which don't prevent the response from being used.  The response is judged against
+// the [DefaultProfiles] in effect when it was produced.
+//
+// cert can be a precertificate, but issuerCert must be the final certificate's issuer,
+// not the precertificate's issuer.
+//
+// Returns an error if the response can't be parsed or does not pertain to cert.
+func Lint(cert *x509.Certificate, issuerCert *x509.Certificate, responseBytes []byte) ([]Finding, error) {
+	return lintResponse(cert, issuerCert, responseBytes, nil)
+}
+
+func lintResponse(cert *x509.Certificate, issuerCert *x509.Certificate, responseBytes []byte, config *Config) ([]Finding, error) {
+	// Don't pass issuerCert so that a responder certificate which wasn't issued by the
+	// issuer is reported as a finding rather than an error.
+	response, err := ocsp.ParseResponseForCert(responseBytes, cert, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing OCSP response: %w", err)
+	}
+
+	input := &lintInput{
+		cert:       cert,
+		issuerCert: issuerCert,
+		response:   response,
+		profile:    ProfileAt(config.profiles(), response.ProducedAt),
+		now:        time.Now(),
+	}
+	findings := []Finding{}
+	for _, l := range lints {
+		if message := l.check(input); message != "" {
+			findings = append(findings, l.finding(message))
+		}
+	}
+	return findings, nil
+}
+
+// Like lintResponse, but for a report of findings: if the response can't be
+// linted, the error is returned as a finding
+func lintFindings(cert *x509.Certificate, issuerCert *x509.Certificate, responseBytes []byte, config *Config) []Finding {
+	findings, err := lintResponse(cert, issuerCert, responseBytes, config)
+	if err != nil {
+		return []Finding{lintFailed.finding(err.Error())}
+	}
+	return findings
+}
+
+func lintSignature(in *lintInput) string {
+	if in.response.Certificate != nil {
+		// ParseResponseForCert already verified the signature using the responder certificate
+		return ""
+	}
+	if err := in.response.CheckSignatureFrom(in.issuerCert); err != nil {
+		return fmt.Sprintf("Response signature is not valid for the issuer: %s", err)
+	}
+	return ""
+}
+
+func lintSHA1(in *lintInput) string {
+	if in.profile.ProhibitSHA1 && isSHA1(in.response.SignatureAlgorithm) {
+		return fmt.Sprintf("Response is signed using %s", in.response.SignatureAlgorithm)
+	}
+	return ""
+}
+
+func lintNextUpdateMissing(in *lintInput) string {
+	if in.profile.RequireNextUpdate && in.response.NextUpdate.IsZero() {
+		return "Response lacks nextUpdate"
+	}
+	return ""
+}
+
+func lintNextUpdateOrder(in *lintInput) string {
+	if !in.response.NextUpdate.IsZero() && in.response.NextUpdate.Before(in.response.ThisUpdate) {
+		return fmt.Sprintf("nextUpdate (%s) is before thisUpdate (%s)", in.response.NextUpdate, in.response.ThisUpdate)
+	}
+	return ""
+}
+
+func lintValidity(in *lintInput) string {
+	if in.response.NextUpdate.IsZero() || in.profile.MaxValidity == 0 {
+		return ""
+	}
+	// The validity interval includes both thisUpdate and nextUpdate (see Section
+	// 4.9.10 of the Baseline Requirements), so it's one second longer than their difference
+	if validity := in.response.NextUpdate.Sub(in.response.ThisUpdate) + time.Second; validity > in.profile.MaxValidity {
+		return fmt.Sprintf("Validity interval of %s exceeds maximum of %s", validity, in.profile.MaxValidity)
+	}
+	return ""
+}
+
+func lintThisUpdateInFuture(in *lintInput) string {
+	if in.response.ThisUpdate.After(in.now.Add(ClockTolerance)) {
+		return fmt.Sprintf("thisUpdate (%s) is in the future", in.response.ThisUpdate)
+	}
+	return ""
+}
+
+func lintExpired(in *lintInput) string {
+	if !in.response.NextUpdate.IsZero() && in.response.NextUpdate.Before(in.now) {
+		return fmt.Sprintf("nextUpdate (%s) is in the past", in.response.NextUpdate)
+	}
+	return ""
+}
+
+func lintResponderIssuer(in *lintInput) string {
+	responderCert := in.response.Certificate
+	if responderCert == nil {
+		return ""
+	}
+	if err := in.issuerCert.CheckSignature(responderCert.SignatureAlgorithm, responderCert.RawTBSCertificate, responderCert.Signature); err != nil {
+		return fmt.Sprintf("Delegated responder certificate was not issued by the issuer: %s", err)
+	}
+	return ""
+}
+
+func lintResponderEKU(in *lintInput) string {
+	if in.response.Certificate != nil && !isOCSPResponderCert(in.response.Certificate) {
+		return "Delegated responder certificate lacks the id-kp-OCSPSigning extended key usage"
+	}
+	return ""
+}
+
+func lintResponderNoCheck(in *lintInput) string {
+	if in.response.Certificate != nil && !hasOCSPNoCheck(in.response.Certificate) {
+		return "Delegated responder certificate lacks the id-pkix-ocsp-nocheck extension"
+	}
+	return ""
+}
+
+func lintResponderExpired(in *lintInput) string {
+	responderCert := in.response.Certificate
+	if responderCert == nil {
+		return ""
+	}
+	if in.response.ProducedAt.Before(responderCert.NotBefore) || in.response.ProducedAt.After(responderCert.NotAfter) {
+		return fmt.Sprintf("Delegated responder certificate was not valid when the response was produced (%s)", in.response.ProducedAt)
+	}
+	return ""
+}
+
+func lintPrecertUnknown(in *lintInput) string {
+	if in.response.Status == ocsp.Unknown && isPrecertificate(in.cert) {
+		return "Responder does not know about a serial number which was used in a precertificate"
+	}
+	return ""
+}
+
+func isPrecertificate(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidCTPoison) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/ocsputil/ocsptest"
+)
+
+func TestLints(t *testing.T) {
+	names := make(map[string]bool)
+	for _, info := range Lints {
+		if names[info.Name] {
+			t.Errorf("lint %s is listed twice", info.Name)
+		}
+		names[info.Name] = true
+		if info.Description == "" {
+			t.Errorf("lint %s has no description", info.Name)
+		}
+	}
+	for _, l := range lints {
+		if !names[l.Name] {
+			t.Errorf("lint %s is missing from Lints", l.Name)
+		}
+	}
+}
+
+// Return the findings from evaluating cert with Lint enabled
+func lintTestFindings(t *testing.T, responder *ocsptest.Responder, certData []byte) map[string]bool {
+	t.Helper()
+	eval := Evaluate(context.Background(), certData, responder.CA.RawSubject, responder.CA.RawSubjectPublicKeyInfo, &Config{Lint: true})
+	findings := make(map[string]bool)
+	for _, finding := range eval.Findings {
+		findings[finding.Lint] = true
+	}
+	return findings
+}
+
+func TestLintValidity(t *testing.T) {
+	thisUpdate := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tests := []struct {
+		validity time.Duration // nextUpdate minus thisUpdate
+		want     bool
+	}{
+		{10*24*time.Hour - time.Second, false},
+		// The validity interval is inclusive, so this is 10 days and 1 second
+		{10 * 24 * time.Hour, true},
+	}
+	for _, test := range tests {
+		responder := newTestResponder(t)
+		cert, _, err := responder.IssueCertificate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		responder.SetStatus(cert.SerialNumber, ocsptest.CertStatus{ThisUpdate: thisUpdate, NextUpdate: thisUpdate.Add(test.validity)})
+		if got := lintTestFindings(t, responder, cert.Raw)["validity_too_long"]; got != test.want {
+			t.Errorf("validity of %s: validity_too_long = %v; want %v", test.validity, got, test.want)
+		}
+	}
+}
+
+func TestLintFailed(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A response for a different certificate can't be linted
+	eval := Evaluate(context.Background(), other.Raw, responder.CA.RawSubject, responder.CA.RawSubjectPublicKeyInfo, nil)
+	if eval.Err != nil {
+		t.Fatal(eval.Err)
+	}
+	responder.SetRawResponse(eval.ResponseBytes)
+	if !lintTestFindings(t, responder, cert.Raw)["lint_failed"] {
+		t.Errorf("failure to lint wasn't reported as a finding")
+	}
+}
@@ -28,13 +28,17 @@ package ocsputil // import "software.sslmate.com/src/ocsputil"
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/asn1"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/ocsp"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -48,6 +52,15 @@ var (
 
 	// ErrNoCheck is returned when the certificate is an OCSP Responder certificate with the OCSP No Check extension
 	ErrNoCheck = errors.New("Certificate is an OCSP responder certificate with the OCSP No Check extension")
+
+	// ErrPhaseTimeout is returned when a phase of an OCSP check exceeds its limit in [PhaseTimeouts]
+	ErrPhaseTimeout = errors.New("Phase of OCSP check exceeded its timeout")
+
+	// ErrNonceMismatch is returned when the OCSP response echoes a nonce which differs from the request's
+	ErrNonceMismatch = errors.New("OCSP response nonce does not match the request nonce")
+
+	// ErrCertIDHashMismatch is returned under [Config].StrictCertIDHash when the response's CertID uses a different hash algorithm than the request's
+	ErrCertIDHashMismatch = errors.New("OCSP response CertID uses a different hash algorithm than the request")
 )
 
 // The maximum amount of time to wait for an OCSP response, as specified by Section
@@ -58,13 +71,23 @@ const QueryTimeout = 10 * time.Second
 
 var oidOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
 
-func getOCSPServer(cert *x509.Certificate) string {
+// Return the certificate's "http://" OCSP responder URLs, or if there are none
+// and allowHTTPS is true, its "https://" URLs
+func getOCSPServers(cert *x509.Certificate, allowHTTPS bool) []string {
+	var servers []string
 	for _, server := range cert.OCSPServer {
 		if strings.HasPrefix(server, "http://") {
-			return server
+			servers = append(servers, server)
 		}
 	}
-	return ""
+	if len(servers) == 0 && allowHTTPS {
+		for _, server := range cert.OCSPServer {
+			if strings.HasPrefix(server, "https://") {
+				servers = append(servers, server)
+			}
+		}
+	}
+	return servers
 }
 
 func isOCSPResponderCert(cert *x509.Certificate) bool {
@@ -97,13 +120,13 @@ func hasOCSPNoCheck(cert *x509.Certificate) bool {
 func ParseCertificate(certData []byte, issuerSubject []byte, issuerPubkeyBytes []byte) (cert *x509.Certificate, issuerCert *x509.Certificate, err error) {
 	cert, err = x509.ParseCertificate(certData)
 	if err != nil {
-		err = fmt.Errorf("unable to parse certificate: %w", err)
+		err = withCode(ErrorCodeCertificateInvalid, fmt.Errorf("unable to parse certificate: %w", err))
 		return
 	}
 
 	issuerPubkey, err := x509.ParsePKIXPublicKey(issuerPubkeyBytes)
 	if err != nil {
-		err = fmt.Errorf("unable to parse issuer public key: %w", err)
+		err = withCode(ErrorCodeCertificateInvalid, fmt.Errorf("unable to parse issuer public key: %w", err))
 		return
 	}
 
@@ -125,7 +148,17 @@ func ParseCertificate(certData []byte, issuerSubject []byte, issuerPubkeyBytes [
 // [ErrNoCheck] if the certificate is an OCSP Responder certificate with the OCSP
 // No Check extension, or an error from [golang.org/x/crypto/ocsp.CreateRequest]
 func CreateRequest(cert *x509.Certificate, issuerCert *x509.Certificate) (serverURL string, requestBytes []byte, err error) {
-	serverURL = getOCSPServer(cert)
+	return createRequest(cert, issuerCert, nil)
+}
+
+func createRequest(cert *x509.Certificate, issuerCert *x509.Certificate, config *Config) (serverURL string, requestBytes []byte, err error) {
+	serverURL, _ = config.responderURL(cert, issuerCert)
+	requestBytes, err = createRequestFor(cert, issuerCert, serverURL, config)
+	return
+}
+
+// Like createRequest, but for a responder URL which has already been chosen
+func createRequestFor(cert *x509.Certificate, issuerCert *x509.Certificate, serverURL string, config *Config) (requestBytes []byte, err error) {
 	if serverURL == "" {
 		err = ErrNoResponder
 		return
@@ -134,17 +167,33 @@ func CreateRequest(cert *x509.Certificate, issuerCert *x509.Certificate) (server
 		err = ErrNoCheck
 		return
 	}
-	requestBytes, err = ocsp.CreateRequest(cert, issuerCert, nil)
+	requestBytes, err = ocsp.CreateRequest(cert, issuerCert, &ocsp.RequestOptions{Hash: config.certIDHash(issuerCert)})
 	if err != nil {
 		err = fmt.Errorf("error creating OCSP request: %w", err)
 		return
 	}
+	if config.nonce() {
+		requestBytes, err = addNonce(requestBytes)
+		if err != nil {
+			err = fmt.Errorf("error adding nonce to OCSP request: %w", err)
+			return
+		}
+	}
 	return
 }
 
 // Given an OCSP server URL and an OCSP request (which can be created with [CreateRequest]),
-// send the OCSP query using a POST request and return the response, which is suitable for
-// passing to [CheckResponse].  The timeout for the query is defined by [QueryTimeout].
+// send the OCSP query and return the response, which is suitable for passing to
+// [CheckResponse].  The timeout for the query is defined by [QueryTimeout],
+// unless [Config].PhaseTimeouts or [Config].AdaptiveTimeout specify otherwise.
+//
+// The query is sent using a POST request, unless [Config].Method specifies otherwise.
+//
+// If [Config].Retries is non-zero, failed attempts are retried as described there,
+// and each attempt is subject to the above timeout.
+//
+// If the request contains a nonce and the response echoes a different one,
+// [ErrNonceMismatch] is returned.
 //
 // If config is nil, a zero-value [Config] is used, which provides
 // sensible defaults.
@@ -156,37 +205,218 @@ func CreateRequest(cert *x509.Certificate, issuerCert *x509.Certificate) (server
 //   - The HTTP response code is not 200
 //   - The Content-Type of the response is not "application/ocsp-response"
 func Query(ctx context.Context, serverURL string, requestBytes []byte, config *Config) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	result, err := query(ctx, serverURL, requestBytes, config)
+	return result.responseBytes, err
+}
+
+// Contains information about an OCSP query, as returned by query
+type queryResult struct {
+	responseBytes []byte
+	responseHash  []byte        // SHA-256 hash of responseBytes, computed as the response is read
+	method        string        // The HTTP method which was used, or empty if no request was sent
+	responseTime  time.Duration // How long the final attempt took
+	timings       Timings       // Breakdown of the final HTTP request
+	header        http.Header   // The headers of the final HTTP response
+	tls           *TLSInfo      // The TLS parameters of the final HTTP response, or nil if not https
+	attempts      int           // The number of attempts made, including retries
+	retryable     bool          // True if the final attempt failed in a way that may succeed if retried
+}
+
+// Like Query, but also return information about how the query was performed
+func query(ctx context.Context, serverURL string, requestBytes []byte, config *Config) (result queryResult, err error) {
+	if deadline := config.queryDeadline(); deadline != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	backoff := config.retryBackoff()
+	for attempt := 1; ; attempt++ {
+		startTime := time.Now()
+		result, err = queryAttempt(ctx, serverURL, requestBytes, config)
+		result.responseTime = time.Since(startTime)
+		result.attempts = attempt
+
+		if !result.retryable || attempt > config.retries() || ctx.Err() != nil {
+			break
+		}
+		if !sleepContext(ctx, backoff) {
+			break
+		}
+		backoff *= 2
+		if waitErr := config.waitForResponder(ctx, serverURL); waitErr != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = checkNonce(requestBytes, result.responseBytes)
+	}
+	return
+}
+
+// Wait for the given duration, returning false if ctx is done first
+func sleepContext(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Make one attempt at an OCSP query, using the method specified by config
+func queryAttempt(ctx context.Context, serverURL string, requestBytes []byte, config *Config) (result queryResult, err error) {
+	switch config.method() {
+	case MethodGET:
+		result, _, err = sendQueryWithTimeout(ctx, http.MethodGet, serverURL, requestBytes, config)
+		return
+	case MethodAuto:
+		if len(makeGetURL(serverURL, requestBytes)) <= maxGetURLSize {
+			var rejected bool
+			result, rejected, err = sendQueryWithTimeout(ctx, http.MethodGet, serverURL, requestBytes, config)
+			if !rejected {
+				return
+			}
+			// The POST request is another query, so it's subject to the rate limit too
+			if err = config.waitForResponder(ctx, serverURL); err != nil {
+				return
+			}
+		}
+	}
+
+	result, _, err = sendQueryWithTimeout(ctx, http.MethodPost, serverURL, requestBytes, config)
+	return
+}
+
+// Like sendQuery, but subject to the query timeout
+func sendQueryWithTimeout(ctx context.Context, method string, serverURL string, requestBytes []byte, config *Config) (result queryResult, rejected bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, config.queryTimeout(serverURL))
 	defer cancel()
+	return sendQuery(ctx, method, serverURL, requestBytes, config)
+}
 
-	httpRequest, err := http.NewRequestWithContext(ctx, "POST", serverURL, bytes.NewBuffer(requestBytes))
+// The maximum size of the URL, including the scheme, host, and path, of an OCSP
+// request that is sent using GET by [MethodAuto], as recommended by Section 5 of RFC 5019.
+const maxGetURLSize = 255
+
+func encodeGetRequest(requestBytes []byte) string {
+	return url.QueryEscape(base64.StdEncoding.EncodeToString(requestBytes))
+}
+
+// Return the URL for sending an OCSP request using GET, as described in Appendix A.1 of RFC 6960
+func makeGetURL(serverURL string, requestBytes []byte) string {
+	encodedRequest := encodeGetRequest(requestBytes)
+	if strings.HasSuffix(serverURL, "/") {
+		return serverURL + encodedRequest
+	} else {
+		return serverURL + "/" + encodedRequest
+	}
+}
+
+// Send an OCSP request using the given HTTP method.  rejected is true if the
+// responder returned an HTTP response which is not a valid OCSP response.
+func sendQuery(ctx context.Context, method string, serverURL string, requestBytes []byte, config *Config) (result queryResult, rejected bool, err error) {
+	trace := new(queryTrace)
+	ctx = trace.withContext(ctx)
+
+	var httpRequest *http.Request
+	if method == http.MethodGet {
+		httpRequest, err = http.NewRequestWithContext(ctx, method, makeGetURL(serverURL, requestBytes), nil)
+	} else {
+		httpRequest, err = http.NewRequestWithContext(ctx, method, serverURL, bytes.NewBuffer(requestBytes))
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error with OCSP responder URL: %w", err)
+		err = fmt.Errorf("error with OCSP responder URL: %w", err)
+		return
+	}
+	if method == http.MethodPost {
+		httpRequest.Header.Set("Content-Type", "application/ocsp-request")
+		httpRequest.Header["Idempotency-Key"] = nil // Forces net/http to retry on failure even though it's a POST request
 	}
-	httpRequest.Header.Set("Content-Type", "application/ocsp-request")
 	httpRequest.Header.Set("User-Agent", config.userAgent())
-	httpRequest.Header["Idempotency-Key"] = nil // Forces net/http to retry on failure even though it's a POST request
+	if err = config.prepareRequest(httpRequest); err != nil {
+		err = fmt.Errorf("error preparing OCSP request: %w", err)
+		return
+	}
+
+	result.method = method
+	startTime := time.Now()
 
-	httpResponse, err := config.httpClient().Do(httpRequest)
+	var statusCode int
+	defer func() {
+		var remoteAddr string
+		remoteAddr, result.timings = trace.info()
+		config.observeResponder(serverURL, time.Since(startTime), err != nil)
+		config.onQueryDone(QueryInfo{
+			ResponderURL: serverURL,
+			Method:       method,
+			RemoteAddr:   remoteAddr,
+			StatusCode:   statusCode,
+			TLS:          result.tls,
+			Timings:      result.timings,
+			Err:          err,
+			DomainNames:  config.queryDomainNames(),
+		})
+	}()
+
+	client, release, err := config.queryClient()
+	if err != nil {
+		return
+	}
+	defer release()
+	httpResponse, err := client.Do(httpRequest)
 	if err != nil {
-		return nil, fmt.Errorf("error querying OCSP responder over HTTP: %w", err)
+		err = withCode(transportErrorCode(err), fmt.Errorf("error querying OCSP responder over HTTP: %w", err))
+		result.retryable = true
+		config.observeFailure(ctx, serverURL, startTime)
+		return
 	}
+	statusCode = httpResponse.StatusCode
+	result.header = httpResponse.Header
+	result.tls = newTLSInfo(httpResponse.TLS)
+	trace.gotResponse()
 
-	body, err := io.ReadAll(httpResponse.Body)
+	hash := sha256.New()
+	body, err := io.ReadAll(io.TeeReader(httpResponse.Body, hash))
 	httpResponse.Body.Close()
+	trace.readBody()
 	if err != nil {
-		return nil, fmt.Errorf("error reading response from OCSP responder: %w", err)
+		err = withCode(ErrorCodeRead, fmt.Errorf("error reading response from OCSP responder: %w", err))
+		result.retryable = true
+		config.observeFailure(ctx, serverURL, startTime)
+		return
 	}
+	config.observeLatency(serverURL, time.Since(startTime))
 
 	if httpResponse.StatusCode != 200 {
-		return nil, fmt.Errorf("HTTP error from OCSP responder: %s", httpResponse.Status)
+		err = withCode(ErrorCodeHTTPStatus, fmt.Errorf("HTTP error from OCSP responder: %s", httpResponse.Status))
+		result.retryable = httpResponse.StatusCode >= 500
+		return result, true, err
 	}
 
 	if contentType := httpResponse.Header.Get("Content-Type"); contentType != "application/ocsp-response" {
-		return nil, fmt.Errorf("HTTP response header has invalid Content-Type value %s", contentType)
+		err = withCode(ErrorCodeContentType, fmt.Errorf("HTTP response header has invalid Content-Type value %s", contentType))
+		return result, true, err
 	}
 
-	return body, nil
+	result.responseBytes = body
+	result.responseHash = hash.Sum(nil)
+	result.retryable = isRetryableResponse(body)
+	return
+}
+
+// Return true if the given OCSP response is malformed, or indicates that
+// the responder had an internal error or wants the client to try later
+func isRetryableResponse(responseBytes []byte) bool {
+	var response responseASN1
+	if rest, err := asn1.Unmarshal(responseBytes, &response); err != nil || len(rest) != 0 {
+		return true
+	}
+	status := ocsp.ResponseStatus(response.Status)
+	return status == ocsp.ServerFailed || status == ocsp.TryLater
 }
 
 // Contains information about when and why a certificate was revoked
@@ -195,33 +425,105 @@ type RevocationInfo struct {
 	Reason int
 }
 
+// Contains the details of an OCSP response, as returned by [CheckResponseDetails]
+type ResponseDetails struct {
+	// One of [golang.org/x/crypto/ocsp.Good], [golang.org/x/crypto/ocsp.Revoked], or [golang.org/x/crypto/ocsp.Unknown]
+	Status int
+
+	// Set only if Status is [golang.org/x/crypto/ocsp.Revoked]
+	RevocationInfo RevocationInfo
+
+	ProducedAt time.Time
+	ThisUpdate time.Time
+	NextUpdate time.Time // Zero if the response lacks nextUpdate
+
+	// Exactly one of ResponderName and ResponderKeyHash is set, depending
+	// on how the response identifies its responder
+	ResponderName    []byte // DER-encoded subject of the responder
+	ResponderKeyHash []byte // SHA-1 hash of the responder's public key
+
+	SignatureAlgorithm x509.SignatureAlgorithm
+	IssuerHash         crypto.Hash // The hash algorithm used in the response's CertID
+
+	// The delegated responder certificate included in the response, or nil if the
+	// response was signed directly by the issuer
+	ResponderCert *x509.Certificate
+
+	// True if the response was reused from [Config].Cache instead of being
+	// obtained from the responder.  Always false when returned by [CheckResponseDetails].
+	FromCache bool
+}
+
+// Return true if the certificate was revoked
+func (details *ResponseDetails) Revoked() bool {
+	return details.Status == ocsp.Revoked
+}
+
 // Given a certificate, its issuer, and an OCSP response, parse the response and
 // return if it was revoked.
 //
 // cert can be a precertificate, but issuerCert must be the final certificate's issuer,
 // not the precertificate's issuer.
 //
+// The response is judged against the [DefaultProfiles] in effect when it was produced;
+// SHA-1-signed responses are rejected if the profile prohibits them.
+//
 // Returns [ErrUnknown] if the response is neither good nor revoked, or an error
 // from [golang.org/x/crypto/ocsp.ParseResponseForCert]
 func CheckResponse(cert *x509.Certificate, issuerCert *x509.Certificate, responseBytes []byte) (revoked bool, info RevocationInfo, err error) {
+	return checkResponse(cert, issuerCert, responseBytes, nil)
+}
+
+func checkResponse(cert *x509.Certificate, issuerCert *x509.Certificate, responseBytes []byte, config *Config) (revoked bool, info RevocationInfo, err error) {
+	details, err := checkResponseDetails(cert, issuerCert, responseBytes, config)
+	if err != nil {
+		return
+	}
+	return details.Revoked(), details.RevocationInfo, nil
+}
+
+// Like [CheckResponse], but return the details of the response.  details is non-nil
+// if the response could be parsed, even if err is non-nil (e.g. [ErrUnknown]).
+func CheckResponseDetails(cert *x509.Certificate, issuerCert *x509.Certificate, responseBytes []byte) (details *ResponseDetails, err error) {
+	return checkResponseDetails(cert, issuerCert, responseBytes, nil)
+}
+
+func checkResponseDetails(cert *x509.Certificate, issuerCert *x509.Certificate, responseBytes []byte, config *Config) (details *ResponseDetails, err error) {
 	response, err := ocsp.ParseResponseForCert(responseBytes, cert, issuerCert)
 	if err != nil {
-		err = fmt.Errorf("error parsing OCSP response: %w", err)
+		err = withCode(parseErrorCode(err), fmt.Errorf("error parsing OCSP response: %w", err))
 		return
 	}
 
-	if isSHA1(response.SignatureAlgorithm) && !response.ProducedAt.Before(time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)) {
-		err = fmt.Errorf("signed using SHA-1")
+	details = &ResponseDetails{
+		Status:             response.Status,
+		ProducedAt:         response.ProducedAt,
+		ThisUpdate:         response.ThisUpdate,
+		NextUpdate:         response.NextUpdate,
+		ResponderName:      response.RawResponderName,
+		ResponderKeyHash:   response.ResponderKeyHash,
+		SignatureAlgorithm: response.SignatureAlgorithm,
+		IssuerHash:         response.IssuerHash,
+		ResponderCert:      response.Certificate,
+	}
+	if response.Status == ocsp.Revoked {
+		details.RevocationInfo.Time = response.RevokedAt
+		details.RevocationInfo.Reason = response.RevocationReason
+	}
+
+	profile := ProfileAt(config.profiles(), response.ProducedAt)
+
+	if isSHA1(response.SignatureAlgorithm) && profile.ProhibitSHA1 {
+		err = withCode(ErrorCodeResponseSHA1, fmt.Errorf("signed using SHA-1"))
 		return
 	}
 
-	if response.Status == ocsp.Good {
-		revoked = false
-	} else if response.Status == ocsp.Revoked {
-		revoked = true
-		info.Time = response.RevokedAt
-		info.Reason = response.RevocationReason
-	} else {
+	if requested := config.certIDHash(issuerCert); config.strictCertIDHash() && response.IssuerHash != requested {
+		err = fmt.Errorf("%w: requested %s, got %s", ErrCertIDHashMismatch, requested, response.IssuerHash)
+		return
+	}
+
+	if response.Status != ocsp.Good && response.Status != ocsp.Revoked {
 		err = ErrUnknown
 	}
 	return
@@ -0,0 +1,71 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMethodAutoURLSize(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, requestBytes, err := CreateRequest(cert, responder.CA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		serverURL  string
+		wantMethod string
+	}{
+		{"short URL", responder.URL, "GET"},
+		// The encoded request fits, but the responder's path pushes the URL over 255 bytes
+		{"long path", responder.URL + "/" + strings.Repeat("a", 255-len(encodeGetRequest(requestBytes))), "POST"},
+	}
+	for _, test := range tests {
+		if size := len(makeGetURL(test.serverURL, requestBytes)); (size <= maxGetURLSize) != (test.wantMethod == "GET") {
+			t.Fatalf("%s: GET URL is %d bytes, which doesn't exercise the limit", test.name, size)
+		}
+		before := responder.Requests()
+		result, err := queryAttempt(context.Background(), test.serverURL, requestBytes, &Config{Method: MethodAuto})
+		if requests := responder.Requests() - before; requests != 1 {
+			t.Errorf("%s: responder received %d requests; want 1", test.name, requests)
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if result.method != test.wantMethod {
+			t.Errorf("%s: request was sent using %s; want %s", test.name, result.method, test.wantMethod)
+		}
+	}
+}
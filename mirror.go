@@ -0,0 +1,218 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The maximum number of mismatched comparisons retained by a [MirrorReport]
+const maxMirrorMismatches = 20
+
+// The outcome of sending an OCSP request to one responder, as part of a [MirrorComparison]
+type MirrorResult struct {
+	ResponderURL string        `json:"responder_url"`
+	Status       string        `json:"status,omitempty"` // "good", "revoked", or "unknown"; empty if the response couldn't be verified
+	ResponseTime time.Duration `json:"response_time"`
+	Error        string        `json:"error,omitempty"`
+	ErrorCode    ErrorCode     `json:"error_code,omitempty"`
+
+	details *ResponseDetails
+}
+
+// The outcome of sending the same OCSP request to a responder and its mirror,
+// as produced by [MirrorQuery]
+type MirrorComparison struct {
+	SerialNumber string       `json:"serial_number"`
+	Primary      MirrorResult `json:"primary"`
+	Mirror       MirrorResult `json:"mirror"`
+
+	// A description of how the results differ, or empty if they agree
+	Mismatch string `json:"mismatch,omitempty"`
+}
+
+// Report whether the responder and its mirror returned equivalent results
+func (comparison *MirrorComparison) Agree() bool {
+	return comparison.Mismatch == ""
+}
+
+// Send the same OCSP request about cert to both the certificate's responder
+// and mirrorURL, concurrently, and compare the results.  This is intended for
+// CA operators migrating to new responder infrastructure, who can run
+// MirrorQuery against a sample of certificates and aggregate the results with
+// a [MirrorReport] before switching the URL in their certificates.
+//
+// The results agree if both responses are verified and have the same status
+// (and, for revoked certificates, the same revocation time and reason), or if
+// both queries fail with the same [ErrorCode].
+//
+// Options are taken from config, with any [IssuerOverride] applied to both
+// responders.  If config is nil, a zero-value [Config] is used, which
+// provides sensible defaults.
+//
+// Returns an error if cert has no responder URL or the request can't be created.
+// Failures of the individual queries are recorded in the comparison.
+func MirrorQuery(ctx context.Context, cert *x509.Certificate, issuerCert *x509.Certificate, mirrorURL string, config *Config) (MirrorComparison, error) {
+	config = config.forIssuer(issuerCert)
+	serverURL, requestBytes, err := createRequest(cert, issuerCert, config)
+	if err != nil {
+		return MirrorComparison{}, err
+	}
+
+	comparison := MirrorComparison{SerialNumber: fmt.Sprintf("%x", cert.SerialNumber)}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		comparison.Primary = mirrorQuery(ctx, cert, issuerCert, serverURL, requestBytes, config)
+	}()
+	go func() {
+		defer wg.Done()
+		comparison.Mirror = mirrorQuery(ctx, cert, issuerCert, mirrorURL, requestBytes, config)
+	}()
+	wg.Wait()
+
+	comparison.Mismatch = compareMirrorResults(&comparison.Primary, &comparison.Mirror)
+	return comparison, nil
+}
+
+func mirrorQuery(ctx context.Context, cert *x509.Certificate, issuerCert *x509.Certificate, serverURL string, requestBytes []byte, config *Config) MirrorResult {
+	result := MirrorResult{ResponderURL: serverURL}
+	err := config.waitForResponder(ctx, serverURL)
+	if err == nil {
+		var queryResult queryResult
+		queryResult, err = query(ctx, serverURL, requestBytes, config)
+		result.ResponseTime = queryResult.responseTime
+		if err == nil {
+			result.details, err = checkResponseDetails(cert, issuerCert, queryResult.responseBytes, config)
+		}
+	}
+	if result.details != nil {
+		result.Status = statusName(result.details.Status)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorCode = ErrorCodeOf(err)
+	}
+	return result
+}
+
+// Return a description of how the primary and mirror results differ, or empty
+// if they agree
+func compareMirrorResults(primary *MirrorResult, mirror *MirrorResult) string {
+	switch {
+	case primary.Status != "" && mirror.Status != "":
+		if primary.Status != mirror.Status {
+			return fmt.Sprintf("primary says %s but mirror says %s", primary.Status, mirror.Status)
+		}
+		if primary.details.Revoked() && primary.details.RevocationInfo != mirror.details.RevocationInfo {
+			return "revocation time or reason differs"
+		}
+		return ""
+	case primary.Status != "":
+		return fmt.Sprintf("primary says %s but mirror failed (%s)", primary.Status, mirror.ErrorCode)
+	case mirror.Status != "":
+		return fmt.Sprintf("primary failed (%s) but mirror says %s", primary.ErrorCode, mirror.Status)
+	case primary.ErrorCode != mirror.ErrorCode:
+		return fmt.Sprintf("primary failed (%s) but mirror failed differently (%s)", primary.ErrorCode, mirror.ErrorCode)
+	default:
+		return ""
+	}
+}
+
+// Aggregates [MirrorComparison]s into a migration readiness report.  The zero
+// value is ready to use, and a MirrorReport is safe for concurrent use.
+type MirrorReport struct {
+	mu              sync.Mutex
+	comparisons     int
+	mismatches      []MirrorComparison
+	numMismatches   int
+	primaryFailures int
+	mirrorFailures  int
+	primaryLatency  []time.Duration
+	mirrorLatency   []time.Duration
+}
+
+// A summary of a [MirrorReport], suitable for serializing as JSON.  Latencies
+// include only successful queries.
+type MirrorSummary struct {
+	Comparisons     int                `json:"comparisons"`
+	Mismatches      int                `json:"mismatches"`
+	PrimaryFailures int                `json:"primary_failures"`
+	MirrorFailures  int                `json:"mirror_failures"`
+	PrimaryLatency  LatencyStats       `json:"primary_latency"`
+	MirrorLatency   LatencyStats       `json:"mirror_latency"`
+	Examples        []MirrorComparison `json:"examples"` // The first 20 mismatched comparisons
+}
+
+// Report whether the mirror can replace the primary responder: at least one
+// comparison was made, every comparison agreed, and the mirror failed no more
+// often than the primary
+func (summary *MirrorSummary) Ready() bool {
+	return summary.Comparisons > 0 && summary.Mismatches == 0 && summary.MirrorFailures <= summary.PrimaryFailures
+}
+
+// Add a comparison to the report
+func (report *MirrorReport) Add(comparison MirrorComparison) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.comparisons++
+	if !comparison.Agree() {
+		report.numMismatches++
+		if len(report.mismatches) < maxMirrorMismatches {
+			report.mismatches = append(report.mismatches, comparison)
+		}
+	}
+	if comparison.Primary.Error != "" {
+		report.primaryFailures++
+	} else {
+		report.primaryLatency = append(report.primaryLatency, comparison.Primary.ResponseTime)
+	}
+	if comparison.Mirror.Error != "" {
+		report.mirrorFailures++
+	} else {
+		report.mirrorLatency = append(report.mirrorLatency, comparison.Mirror.ResponseTime)
+	}
+}
+
+// Return a summary of the comparisons added so far
+func (report *MirrorReport) Summary() MirrorSummary {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	return MirrorSummary{
+		Comparisons:     report.comparisons,
+		Mismatches:      report.numMismatches,
+		PrimaryFailures: report.primaryFailures,
+		MirrorFailures:  report.mirrorFailures,
+		PrimaryLatency:  latencyStats(report.primaryLatency),
+		MirrorLatency:   latencyStats(report.mirrorLatency),
+		Examples:        append([]MirrorComparison(nil), report.mismatches...),
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+)
+
+// The nonce extension, defined by Section 4.4.1 of RFC 6960
+var oidOCSPNonce = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+// The length of nonces generated by addNonce, as recommended by RFC 8954
+const nonceSize = 32
+
+// The subset of the OCSPRequest structure (Section 4.1.1 of RFC 6960) needed to
+// add and extract request extensions.  The RequestList is kept verbatim.
+type requestASN1 struct {
+	TBSRequest tbsRequestASN1
+}
+
+type tbsRequestASN1 struct {
+	Version           int           `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName     asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	RequestList       asn1.RawValue
+	RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+// The subset of the OCSPResponse structure (Section 4.2.1 of RFC 6960) needed to
+// check the response status and extract response extensions
+type responseASN1 struct {
+	Status   asn1.Enumerated
+	Response responseBytesASN1 `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytesASN1 struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponseASN1 struct {
+	TBSResponseData responseDataASN1
+}
+
+type responseDataASN1 struct {
+	Version            int `asn1:"explicit,tag:0,default:0,optional"`
+	ResponderID        asn1.RawValue
+	ProducedAt         asn1.RawValue
+	Responses          asn1.RawValue
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// Return a copy of the given OCSP request with a random nonce extension added
+func addNonce(requestBytes []byte) ([]byte, error) {
+	var request requestASN1
+	if rest, err := asn1.Unmarshal(requestBytes, &request); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, asn1.SyntaxError{Msg: "trailing data after OCSP request"}
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	value, err := asn1.Marshal(nonce)
+	if err != nil {
+		return nil, err
+	}
+	request.TBSRequest.RequestExtensions = append(request.TBSRequest.RequestExtensions, pkix.Extension{Id: oidOCSPNonce, Value: value})
+	return asn1.Marshal(request)
+}
+
+// Return the nonce in the given OCSP request, or nil if it doesn't have one
+func requestNonce(requestBytes []byte) []byte {
+	var request requestASN1
+	if _, err := asn1.Unmarshal(requestBytes, &request); err != nil {
+		return nil
+	}
+	return findNonce(request.TBSRequest.RequestExtensions)
+}
+
+// Return the nonce in the given OCSP response, or nil if it doesn't have one
+func responseNonce(responseBytes []byte) []byte {
+	var response responseASN1
+	if _, err := asn1.Unmarshal(responseBytes, &response); err != nil {
+		return nil
+	}
+	var basicResponse basicResponseASN1
+	if _, err := asn1.Unmarshal(response.Response.Response, &basicResponse); err != nil {
+		return nil
+	}
+	return findNonce(basicResponse.TBSResponseData.ResponseExtensions)
+}
+
+func findNonce(extensions []pkix.Extension) []byte {
+	for _, ext := range extensions {
+		if ext.Id.Equal(oidOCSPNonce) {
+			var nonce []byte
+			if _, err := asn1.Unmarshal(ext.Value, &nonce); err != nil {
+				// Some responders echo the nonce without the OCTET STRING wrapping
+				return ext.Value
+			}
+			return nonce
+		}
+	}
+	return nil
+}
+
+// Return ErrNonceMismatch if the request contains a nonce and the response
+// contains a different one.  A response without a nonce is accepted, since
+// many responders don't support nonces.
+func checkNonce(requestBytes []byte, responseBytes []byte) error {
+	nonce := requestNonce(requestBytes)
+	if nonce == nil {
+		return nil
+	}
+	if echoed := responseNonce(responseBytes); echoed != nil && !bytes.Equal(echoed, nonce) {
+		return ErrNonceMismatch
+	}
+	return nil
+}
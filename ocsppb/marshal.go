@@ -0,0 +1,162 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+// Package ocsppb serializes ocsputil results as Protocol Buffers, for shipping
+// them over gRPC or storing them in pipelines where JSON is too bulky.
+//
+// The schema is in ocsputil.proto, which can be compiled with protoc to obtain
+// types for decoding.  This package encodes directly to the wire format, so
+// it doesn't depend on a Protocol Buffers runtime.
+package ocsppb // import "software.sslmate.com/src/ocsputil/ocsppb"
+
+import (
+	"crypto/x509"
+	"sort"
+	"time"
+
+	"software.sslmate.com/src/ocsputil"
+)
+
+// Encode eval as an Evaluation message
+func MarshalEvaluation(eval *ocsputil.Evaluation) []byte {
+	var e encoder
+	if eval.ResponderURL != nil {
+		e.string(1, *eval.ResponderURL)
+	}
+	e.bytes(2, eval.RequestBytes)
+	if eval.Method != nil {
+		e.string(3, *eval.Method)
+	}
+	e.bytes(4, eval.ResponseBytes)
+	e.bytes(5, eval.ResponseHash)
+	e.duration(6, eval.ResponseTime)
+	e.message(7, marshalTimings(&eval.Timings))
+	e.varint(8, uint64(eval.Attempts))
+	if eval.Response != nil {
+		e.message(9, MarshalResponseDetails(eval.Response))
+	}
+	for i := range eval.Findings {
+		e.message(10, MarshalFinding(&eval.Findings[i]))
+	}
+	keys := make([]string, 0, len(eval.Vantage))
+	for key := range eval.Vantage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry encoder
+		entry.string(1, key)
+		entry.string(2, eval.Vantage[key])
+		e.message(11, entry.buf)
+	}
+	if eval.Err != nil {
+		e.string(12, eval.Err.Error())
+		e.string(13, string(ocsputil.ErrorCodeOf(eval.Err)))
+	}
+	if eval.TLS != nil {
+		e.message(14, marshalTLSInfo(eval.TLS))
+	}
+	if eval.ResponderURLReason != "" {
+		e.string(15, eval.ResponderURLReason)
+	}
+	for _, name := range eval.DomainNames {
+		e.string(16, name)
+	}
+	return e.buf
+}
+
+func marshalTLSInfo(info *ocsputil.TLSInfo) []byte {
+	var e encoder
+	e.varint(1, uint64(info.Version))
+	e.varint(2, uint64(info.CipherSuite))
+	return e.buf
+}
+
+func marshalTimings(timings *ocsputil.Timings) []byte {
+	var e encoder
+	e.duration(1, timings.DNS)
+	e.duration(2, timings.Connect)
+	e.duration(3, timings.TLSHandshake)
+	e.duration(4, timings.FirstByte)
+	e.duration(5, timings.BodyRead)
+	return e.buf
+}
+
+// Encode details as a ResponseDetails message
+func MarshalResponseDetails(details *ocsputil.ResponseDetails) []byte {
+	var e encoder
+	e.varint(1, uint64(details.Status))
+	if details.Revoked() {
+		e.timestamp(2, details.RevocationInfo.Time)
+		e.varint(3, uint64(details.RevocationInfo.Reason))
+	}
+	e.timestamp(4, details.ProducedAt)
+	e.timestamp(5, details.ThisUpdate)
+	e.timestamp(6, details.NextUpdate)
+	e.bytes(7, details.ResponderName)
+	e.bytes(8, details.ResponderKeyHash)
+	if details.SignatureAlgorithm != x509.UnknownSignatureAlgorithm {
+		e.string(9, details.SignatureAlgorithm.String())
+	}
+	if details.IssuerHash != 0 {
+		e.string(10, details.IssuerHash.String())
+	}
+	if details.ResponderCert != nil {
+		e.bytes(11, details.ResponderCert.Raw)
+	}
+	if details.FromCache {
+		e.varint(12, 1)
+	}
+	return e.buf
+}
+
+// Encode finding as a Finding message
+func MarshalFinding(finding *ocsputil.Finding) []byte {
+	var e encoder
+	e.string(1, finding.Lint)
+	e.varint(2, uint64(finding.Severity))
+	e.string(3, finding.Message)
+	return e.buf
+}
+
+func (e *encoder) timestamp(fieldNum int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	var ts encoder
+	ts.varint(1, uint64(t.Unix()))
+	ts.varint(2, uint64(t.Nanosecond()))
+	e.message(fieldNum, ts.buf)
+}
+
+func (e *encoder) duration(fieldNum int, d time.Duration) {
+	if d == 0 {
+		return
+	}
+	var ds encoder
+	ds.varint(1, uint64(int64(d/time.Second)))
+	ds.varint(2, uint64(int64(d%time.Second)))
+	e.message(fieldNum, ds.buf)
+}
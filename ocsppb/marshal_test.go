@@ -0,0 +1,115 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsppb
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	"software.sslmate.com/src/ocsputil"
+)
+
+func TestMarshalEvaluation(t *testing.T) {
+	responderURL := "http://o.test"
+	method := "POST"
+	at := time.Unix(1700000000, 0)
+	eval := &ocsputil.Evaluation{
+		ResponderURL:       &responderURL,
+		ResponderURLReason: "only URL",
+		RequestBytes:       []byte{0x30, 0x00},
+		Method:             &method,
+		ResponseHash:       []byte{0xab},
+		ResponseTime:       1500 * time.Millisecond,
+		Attempts:           1,
+		TLS:                &ocsputil.TLSInfo{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256},
+		Response: &ocsputil.ResponseDetails{
+			Status:             ocsp.Revoked,
+			RevocationInfo:     ocsputil.RevocationInfo{Time: at, Reason: 1},
+			ProducedAt:         at,
+			ThisUpdate:         at,
+			SignatureAlgorithm: x509.SHA256WithRSA,
+			IssuerHash:         crypto.SHA1,
+		},
+		Findings:    []ocsputil.Finding{{Lint: "x", Severity: ocsputil.SeverityWarning, Message: "m"}},
+		Vantage:     map[string]string{"b": "2", "a": "1"},
+		DomainNames: []string{"a.test", "b.test"},
+		Err:         ocsputil.ErrUnknown,
+	}
+
+	timestamp := "\x08\x80\xe2\xcf\xaa\x06" // seconds = 1700000000
+	want := "" +
+		"\x0a\x0dhttp://o.test" + // responder_url
+		"\x12\x02\x30\x00" + // request_bytes
+		"\x1a\x04POST" + // method
+		"\x2a\x01\xab" + // response_hash
+		"\x32\x08\x08\x01\x10\x80\xca\xb5\xee\x01" + // response_time = 1s + 500000000ns
+		"\x3a\x00" + // timings, which is empty
+		"\x40\x01" + // attempts
+		"\x4a\x2f" + // response, containing the following 47 bytes
+		"\x08\x01" + // response.status = REVOKED
+		"\x12\x06" + timestamp + // response.revocation_time
+		"\x18\x01" + // response.revocation_reason
+		"\x22\x06" + timestamp + // response.produced_at
+		"\x2a\x06" + timestamp + // response.this_update
+		"\x4a\x0aSHA256-RSA" + // response.signature_algorithm
+		"\x52\x05SHA-1" + // response.issuer_hash
+		"\x52\x08\x0a\x01x\x10\x01\x1a\x01m" + // findings
+		"\x5a\x06\x0a\x01a\x12\x011" + // vantage, sorted by key
+		"\x5a\x06\x0a\x01b\x12\x012" +
+		"\x62\x2dOCSP responder does not know this certificate" + // error
+		"\x6a\x07unknown" + // error_code
+		"\x72\x06\x08\x84\x06\x10\x81\x26" + // tls = {0x0304, 0x1301}
+		"\x7a\x08only URL" + // responder_url_reason
+		"\x82\x01\x06a.test" + // domain_names
+		"\x82\x01\x06b.test"
+
+	if got := MarshalEvaluation(eval); !bytes.Equal(got, []byte(want)) {
+		t.Errorf("MarshalEvaluation:\ngot  %x\nwant %x", got, want)
+	}
+}
+
+func TestMarshalEvaluationEmpty(t *testing.T) {
+	// Only the timings message is encoded, since messages are encoded even if empty
+	want := []byte{0x3a, 0x00}
+	if got := MarshalEvaluation(&ocsputil.Evaluation{}); !bytes.Equal(got, want) {
+		t.Errorf("MarshalEvaluation: got %x, want %x", got, want)
+	}
+}
+
+func TestMarshalNegativeDuration(t *testing.T) {
+	var e encoder
+	e.duration(1, -time.Second)
+	// seconds = -1, as a ten-byte varint
+	want := []byte{0x0a, 0x0b, 0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	if !bytes.Equal(e.buf, want) {
+		t.Errorf("duration: got %x, want %x", e.buf, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsppb
+
+import (
+	"encoding/binary"
+)
+
+// Wire types, from https://protobuf.dev/programming-guides/encoding/
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// Encodes fields in the Protocol Buffers wire format.  Zero-valued integer and
+// bytes fields are omitted, as in proto3.  Strings are always encoded, so that
+// optional string fields retain their presence.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) tag(fieldNum int, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(fieldNum)<<3|uint64(wireType))
+}
+
+// Encode an integer field.  Negative int32 and int64 values must be converted
+// with uint64(int64(v)), which yields the ten-byte encoding protobuf expects.
+func (e *encoder) varint(fieldNum int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(fieldNum, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) bytes(fieldNum int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	e.lengthDelimited(fieldNum, b)
+}
+
+func (e *encoder) string(fieldNum int, s string) {
+	e.tag(fieldNum, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// Encode an embedded message field.  Unlike scalars, messages are encoded even if empty.
+func (e *encoder) message(fieldNum int, b []byte) {
+	e.lengthDelimited(fieldNum, b)
+}
+
+func (e *encoder) lengthDelimited(fieldNum int, b []byte) {
+	e.tag(fieldNum, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+// Package ocsptest provides utilities for testing code that checks OCSP responses.
+package ocsptest // import "software.sslmate.com/src/ocsputil/ocsptest"
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var idPKIXOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+// These structures mirror those in golang.org/x/crypto/ocsp.  basicResponse
+// keeps the signed TBSResponseData and the signature algorithm as raw values, so
+// that re-marshalling a basicResponse reproduces them byte for byte.  In contrast,
+// responseData is re-encoded from its parsed fields, which can change the encoding
+// of parts that weren't modified (e.g. fractional-second times, or an explicit
+// revocation reason of 0), so it should only be re-marshalled when it's modified.
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    asn1.RawValue // a responseData
+	SignatureAlgorithm asn1.RawValue // a pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []singleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type certID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+type singleResponse struct {
+	CertID           certID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          revokedInfo      `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+func parseBasicResponse(responseDER []byte) (*basicResponse, error) {
+	var response ocspResponse
+	if rest, err := asn1.Unmarshal(responseDER, &response); err != nil {
+		return nil, fmt.Errorf("error parsing OCSP response: %w", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after OCSP response")
+	}
+	if response.Status != 0 {
+		return nil, fmt.Errorf("OCSP response has unsuccessful status %d", response.Status)
+	}
+	if !response.Response.ResponseType.Equal(idPKIXOCSPBasic) {
+		return nil, fmt.Errorf("OCSP response has unsupported type %s", response.Response.ResponseType)
+	}
+
+	basic := new(basicResponse)
+	if rest, err := asn1.Unmarshal(response.Response.Response, basic); err != nil {
+		return nil, fmt.Errorf("error parsing basic OCSP response: %w", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after basic OCSP response")
+	}
+	tbs, err := parseResponseData(basic.TBSResponseData)
+	if err != nil {
+		return nil, err
+	}
+	if len(tbs.Responses) == 0 {
+		return nil, errors.New("OCSP response contains no responses")
+	}
+	return basic, nil
+}
+
+func parseResponseData(raw asn1.RawValue) (*responseData, error) {
+	tbs := new(responseData)
+	if rest, err := asn1.Unmarshal(raw.FullBytes, tbs); err != nil {
+		return nil, fmt.Errorf("error parsing OCSP response data: %w", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after OCSP response data")
+	}
+	return tbs, nil
+}
+
+func marshalResponseData(tbs *responseData) (asn1.RawValue, error) {
+	tbsDER, err := asn1.Marshal(*tbs)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	return asn1.RawValue{FullBytes: tbsDER}, nil
+}
+
+func marshalBasicResponse(basic *basicResponse) ([]byte, error) {
+	basicDER, err := asn1.Marshal(*basic)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ocspResponse{
+		Status: 0,
+		Response: responseBytes{
+			ResponseType: idPKIXOCSPBasic,
+			Response:     basicDER,
+		},
+	})
+}
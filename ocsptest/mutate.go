@@ -0,0 +1,170 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsptest
+
+import (
+	"crypto/sha1"
+	"encoding/asn1"
+	"math/big"
+	"time"
+)
+
+// A systematically altered variant of an OCSP response, produced by [Mutate].
+type Mutation struct {
+	// A short, stable identifier for the kind of mutation, such as "signature-bit-flip"
+	Name string
+
+	// The DER-encoded mutated response
+	ResponseBytes []byte
+}
+
+type mutator struct {
+	name  string
+	apply func(*basicResponse) bool
+}
+
+var mutators = []mutator{
+	{"signature-bit-flip", mutateSignature},
+	{"serial-altered", mutateResponseData(mutateSerial)},
+	{"status-altered", mutateResponseData(mutateStatus)},
+	{"this-update-altered", mutateResponseData(mutateThisUpdate)},
+	{"next-update-stripped", mutateResponseData(stripNextUpdate)},
+	{"responder-id-altered", mutateResponseData(mutateResponderID)},
+	{"certificates-stripped", stripCertificates},
+}
+
+// Adapt a function which alters the TBSResponseData, which is re-encoded only
+// if the function returns true, so that other mutations leave it intact
+func mutateResponseData(apply func(*responseData) bool) func(*basicResponse) bool {
+	return func(basic *basicResponse) bool {
+		tbs, err := parseResponseData(basic.TBSResponseData)
+		if err != nil || !apply(tbs) {
+			return false
+		}
+		if basic.TBSResponseData, err = marshalResponseData(tbs); err != nil {
+			return false
+		}
+		return true
+	}
+}
+
+// Given a valid, DER-encoded OCSP response, return variants of it which have
+// been altered in ways that a relying party must detect: a bit flipped in the
+// signature, a different serial number, a different certificate status, a
+// different thisUpdate, a missing nextUpdate, a different responder ID, and
+// missing responder certificates.  None of the variants are re-signed, so a
+// relying party which accepts any of them is not properly verifying responses.
+//
+// Mutations that don't apply to the response (e.g. stripping nextUpdate
+// from a response which lacks it) are omitted from the result.  The
+// mutations are applied to the first SingleResponse in the response.
+//
+// Returns an error if responseBytes is not a successful basic OCSP response.
+func Mutate(responseBytes []byte) ([]Mutation, error) {
+	if _, err := parseBasicResponse(responseBytes); err != nil {
+		return nil, err
+	}
+
+	mutations := make([]Mutation, 0, len(mutators))
+	for _, m := range mutators {
+		basic, _ := parseBasicResponse(responseBytes)
+		if !m.apply(basic) {
+			continue
+		}
+		mutatedBytes, err := marshalBasicResponse(basic)
+		if err != nil {
+			return nil, err
+		}
+		mutations = append(mutations, Mutation{Name: m.name, ResponseBytes: mutatedBytes})
+	}
+	return mutations, nil
+}
+
+func mutateSignature(basic *basicResponse) bool {
+	if len(basic.Signature.Bytes) == 0 {
+		return false
+	}
+	basic.Signature.Bytes[len(basic.Signature.Bytes)/2] ^= 0x01
+	return true
+}
+
+func mutateSerial(tbs *responseData) bool {
+	single := &tbs.Responses[0]
+	single.CertID.SerialNumber = new(big.Int).Add(single.CertID.SerialNumber, big.NewInt(1))
+	return true
+}
+
+func mutateStatus(tbs *responseData) bool {
+	single := &tbs.Responses[0]
+	if single.Good {
+		single.Good = false
+		single.Revoked = revokedInfo{RevocationTime: single.ThisUpdate}
+	} else {
+		single.Good = true
+		single.Revoked = revokedInfo{}
+		single.Unknown = false
+	}
+	return true
+}
+
+func mutateThisUpdate(tbs *responseData) bool {
+	single := &tbs.Responses[0]
+	single.ThisUpdate = single.ThisUpdate.Add(time.Hour)
+	return true
+}
+
+func stripNextUpdate(tbs *responseData) bool {
+	single := &tbs.Responses[0]
+	if single.NextUpdate.IsZero() {
+		return false
+	}
+	single.NextUpdate = time.Time{}
+	return true
+}
+
+func mutateResponderID(tbs *responseData) bool {
+	// Replace the responder ID with a byKey ID (tag 2) containing a hash that can't match the responder's key
+	keyHash := sha1.Sum(tbs.RawResponderID.FullBytes)
+	keyHashDER, err := asn1.Marshal(keyHash[:])
+	if err != nil {
+		return false
+	}
+	tbs.RawResponderID = asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        2,
+		IsCompound: true,
+		Bytes:      keyHashDER,
+	}
+	return true
+}
+
+func stripCertificates(basic *basicResponse) bool {
+	if len(basic.Certificates) == 0 {
+		return false
+	}
+	basic.Certificates = nil
+	return true
+}
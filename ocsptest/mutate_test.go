@@ -0,0 +1,90 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsptest
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestMutate(t *testing.T) {
+	responder, err := NewResponder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+	if _, err := responder.UseDelegatedResponder(); err != nil {
+		t.Fatal(err)
+	}
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestBytes, err := ocsp.CreateRequest(cert, responder.CA, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responseBytes := responder.respond(requestBytes)
+	if _, err := ocsp.ParseResponseForCert(responseBytes, cert, responder.CA); err != nil {
+		t.Fatalf("unmutated response is invalid: %s", err)
+	}
+
+	// Re-encoding an unaltered response must not change it, or mutations
+	// would be rejected for the wrong reason
+	basic, err := parseBasicResponse(responseBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roundTrip, err := marshalBasicResponse(basic); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(roundTrip, responseBytes) {
+		t.Errorf("re-encoded response differs from the original")
+	}
+
+	mutations, err := Mutate(responseBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mutations) != len(mutators) {
+		t.Errorf("Mutate returned %d mutations; want %d", len(mutations), len(mutators))
+	}
+	for _, mutation := range mutations {
+		if bytes.Equal(mutation.ResponseBytes, responseBytes) {
+			t.Errorf("%s: response is unchanged", mutation.Name)
+		}
+		if _, err := ocsp.ParseResponseForCert(mutation.ResponseBytes, cert, responder.CA); err == nil {
+			t.Errorf("%s: mutated response was accepted", mutation.Name)
+		}
+	}
+}
+
+func TestMutateInvalid(t *testing.T) {
+	if _, err := Mutate(ocsp.TryLaterErrorResponse); err == nil {
+		t.Errorf("Mutate accepted an unsuccessful response")
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsptest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var oidOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+
+// The path, relative to [Responder].URL, at which the CA certificate is served
+const issuerPath = "/issuer.crt"
+
+// The status of a certificate, as reported by a [Responder]
+type CertStatus struct {
+	// [ocsp.Good], [ocsp.Revoked], or [ocsp.Unknown]
+	Status int
+
+	// The revocation time and reason, used only if Status is [ocsp.Revoked].
+	// If RevokedAt is zero, one hour before the response is produced is used.
+	RevokedAt        time.Time
+	RevocationReason int
+
+	// The validity window of the response.  If ThisUpdate is zero, one hour before
+	// the response is produced is used.  If NextUpdate is zero, one day after
+	// ThisUpdate is used, unless OmitNextUpdate is true.
+	ThisUpdate     time.Time
+	NextUpdate     time.Time
+	OmitNextUpdate bool
+}
+
+// An in-process OCSP responder, backed by an [httptest.Server], for testing code
+// which queries OCSP responders without depending on the network.  Responses are
+// signed by the CA, or by a delegated responder if [Responder.UseDelegatedResponder]
+// has been called.
+//
+// The status of each serial number can be set with [Responder.SetStatus].  Serial
+// numbers without a status are reported as unknown, unless [Responder.SetDefaultStatus]
+// specifies otherwise.  Faults can be injected with
+// [Responder.SetLatency], [Responder.SetHTTPError], and [Responder.SetRawResponse].
+//
+// The CA certificate is also served at URL + "/issuer.crt", which is included as
+// the caIssuers URL of certificates from [Responder.IssueCertificate].
+//
+// A Responder is safe for concurrent use.
+type Responder struct {
+	// The URL of the responder, such as "http://127.0.0.1:12345"
+	URL string
+
+	// The CA whose certificates the responder answers for
+	CA    *x509.Certificate
+	CAKey crypto.Signer
+
+	server *httptest.Server
+
+	mu            sync.Mutex
+	signerCert    *x509.Certificate // nil if responses are signed by the CA
+	signerKey     crypto.Signer
+	statuses      map[string]CertStatus
+	defaultStatus CertStatus
+	nextSerial    int64
+	latency       time.Duration
+	httpError     int
+	rawResponse   []byte
+	requests      int
+}
+
+// Create a new Responder for a freshly-generated test CA.  Call [Responder.Close]
+// when done with it.
+func NewResponder() (*Responder, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ocsptest CA"},
+		NotBefore:             now.Add(-24 * time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponderForCA(cert, key), nil
+}
+
+// Create a new Responder for the given CA.  Call [Responder.Close] when done with it.
+func NewResponderForCA(ca *x509.Certificate, caKey crypto.Signer) *Responder {
+	responder := &Responder{
+		CA:            ca,
+		CAKey:         caKey,
+		statuses:      make(map[string]CertStatus),
+		defaultStatus: CertStatus{Status: ocsp.Unknown},
+		nextSerial:    1000,
+	}
+	responder.server = httptest.NewServer(http.HandlerFunc(responder.serveHTTP))
+	responder.URL = responder.server.URL
+	return responder
+}
+
+// Shut down the responder's HTTP server
+func (responder *Responder) Close() {
+	responder.server.Close()
+}
+
+// Issue a leaf certificate from the CA, with the responder's URL as its OCSP
+// responder URL, and set its status to good.  The certificate's private key is
+// also returned, e.g. for use with [ocsputil.Stapler].
+func (responder *Responder) IssueCertificate() (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	responder.mu.Lock()
+	serial := big.NewInt(responder.nextSerial)
+	responder.nextSerial++
+	responder.mu.Unlock()
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "example.com"},
+		DNSNames:              []string{"example.com"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(90 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		OCSPServer:            []string{responder.URL},
+		IssuingCertificateURL: []string{responder.URL + issuerPath},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, responder.CA, key.Public(), responder.CAKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, nil, err
+	}
+	responder.SetStatus(serial, CertStatus{Status: ocsp.Good})
+	return cert, key, nil
+}
+
+// Sign subsequent responses with a newly-generated delegated responder certificate,
+// issued by the CA with the OCSP Signing extended key usage and the OCSP No Check
+// extension.  The delegated responder certificate is included in responses, and
+// returned.
+func (responder *Responder) UseDelegatedResponder() (*x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		Subject:         pkix.Name{CommonName: "ocsptest Responder"},
+		NotBefore:       now.Add(-time.Hour),
+		NotAfter:        now.Add(30 * 24 * time.Hour),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		ExtraExtensions: []pkix.Extension{{Id: oidOCSPNoCheck, Value: asn1.NullBytes}},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, responder.CA, key.Public(), responder.CAKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
+
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+	responder.signerCert = cert
+	responder.signerKey = key
+	return cert, nil
+}
+
+// Set the status reported for the given serial number
+func (responder *Responder) SetStatus(serial *big.Int, status CertStatus) {
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+	responder.statuses[serial.String()] = status
+}
+
+// Set the status reported for serial numbers which were not issued by
+// [Responder.IssueCertificate] or passed to [Responder.SetStatus].  Initially,
+// such serial numbers are reported as unknown.  Setting the status to good
+// simulates a responder which violates Section 4.9.10 of the Baseline Requirements.
+func (responder *Responder) SetDefaultStatus(status CertStatus) {
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+	responder.defaultStatus = status
+}
+
+// Delay every response by the given duration.  If zero, responses are not delayed.
+func (responder *Responder) SetLatency(latency time.Duration) {
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+	responder.latency = latency
+}
+
+// Respond to every request with the given HTTP status code and an empty body.
+// If zero, requests are answered normally.
+func (responder *Responder) SetHTTPError(statusCode int) {
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+	responder.httpError = statusCode
+}
+
+// Respond to every request with the given body, which need not be a valid
+// OCSP response.  Useful values include [ocsp.TryLaterErrorResponse] and
+// [ocsp.UnauthorizedErrorResponse].  If nil, requests are answered normally.
+func (responder *Responder) SetRawResponse(body []byte) {
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+	responder.rawResponse = body
+}
+
+// Return the number of OCSP requests the responder has received
+func (responder *Responder) Requests() int {
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+	return responder.requests
+}
+
+func (responder *Responder) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet && req.URL.Path == issuerPath {
+		w.Header().Set("Content-Type", "application/pkix-cert")
+		w.Write(responder.CA.Raw)
+		return
+	}
+
+	responder.mu.Lock()
+	responder.requests++
+	latency := responder.latency
+	httpError := responder.httpError
+	rawResponse := responder.rawResponse
+	responder.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-req.Context().Done():
+			return
+		}
+	}
+	if httpError != 0 {
+		w.WriteHeader(httpError)
+		return
+	}
+
+	var responseBytes []byte
+	if rawResponse != nil {
+		responseBytes = rawResponse
+	} else {
+		requestBytes, err := readRequest(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responseBytes = responder.respond(requestBytes)
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(responseBytes)
+}
+
+func readRequest(req *http.Request) ([]byte, error) {
+	switch req.Method {
+	case http.MethodGet:
+		encoded, err := url.PathUnescape(strings.TrimPrefix(req.URL.EscapedPath(), "/"))
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(encoded)
+	case http.MethodPost:
+		return io.ReadAll(req.Body)
+	default:
+		return nil, errors.New("unsupported HTTP method")
+	}
+}
+
+// Return a signed response to the given request
+func (responder *Responder) respond(requestBytes []byte) []byte {
+	request, err := ocsp.ParseRequest(requestBytes)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse
+	}
+	if !responder.isIssuer(request) {
+		return ocsp.UnauthorizedErrorResponse
+	}
+
+	responder.mu.Lock()
+	status, ok := responder.statuses[request.SerialNumber.String()]
+	if !ok {
+		status = responder.defaultStatus
+	}
+	signerCert, signerKey := responder.signerCert, responder.signerKey
+	responder.mu.Unlock()
+	if signerCert == nil {
+		signerCert, signerKey = responder.CA, responder.CAKey
+	}
+
+	now := time.Now().Truncate(time.Second)
+	template := ocsp.Response{
+		Status:       status.Status,
+		SerialNumber: request.SerialNumber,
+		ThisUpdate:   status.ThisUpdate,
+		NextUpdate:   status.NextUpdate,
+		IssuerHash:   request.HashAlgorithm,
+	}
+	if template.ThisUpdate.IsZero() {
+		template.ThisUpdate = now.Add(-time.Hour)
+	}
+	if template.NextUpdate.IsZero() && !status.OmitNextUpdate {
+		template.NextUpdate = template.ThisUpdate.Add(24 * time.Hour)
+	}
+	if status.Status == ocsp.Revoked {
+		template.RevokedAt = status.RevokedAt
+		template.RevocationReason = status.RevocationReason
+		if template.RevokedAt.IsZero() {
+			template.RevokedAt = now.Add(-time.Hour)
+		}
+	}
+	if signerCert != responder.CA {
+		template.Certificate = signerCert
+	}
+
+	responseBytes, err := ocsp.CreateResponse(responder.CA, signerCert, template, signerKey)
+	if err != nil {
+		return ocsp.InternalErrorErrorResponse
+	}
+	return responseBytes
+}
+
+// Report whether the request is for a certificate issued by the responder's CA
+func (responder *Responder) isIssuer(request *ocsp.Request) bool {
+	if !request.HashAlgorithm.Available() {
+		return false
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(responder.CA.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false
+	}
+	keyHash := request.HashAlgorithm.New()
+	keyHash.Write(spki.PublicKey.RightAlign())
+	nameHash := request.HashAlgorithm.New()
+	nameHash.Write(responder.CA.RawSubject)
+	return bytes.Equal(keyHash.Sum(nil), request.IssuerKeyHash) && bytes.Equal(nameHash.Sum(nil), request.IssuerNameHash)
+}
@@ -0,0 +1,141 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsptest
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Send an OCSP request about cert to responder over HTTP, and return the verified response
+func queryResponder(t *testing.T, responder *Responder, cert *x509.Certificate) (*ocsp.Response, error) {
+	requestBytes, err := ocsp.CreateRequest(cert, responder.CA, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp, err := http.Post(responder.URL, "application/ocsp-request", bytes.NewReader(requestBytes))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	responseBytes, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return ocsp.ParseResponseForCert(responseBytes, cert, responder.CA)
+}
+
+func TestResponderStatus(t *testing.T) {
+	responder, err := NewResponder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unissued := &x509.Certificate{SerialNumber: big.NewInt(1 << 40)}
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		set  func()
+		want int
+	}{
+		{"issued", cert, func() {}, ocsp.Good},
+		{"revoked", cert, func() { responder.SetStatus(cert.SerialNumber, CertStatus{Status: ocsp.Revoked}) }, ocsp.Revoked},
+		{"unissued", unissued, func() {}, ocsp.Unknown},
+		{"unissued with default", unissued, func() { responder.SetDefaultStatus(CertStatus{Status: ocsp.Good}) }, ocsp.Good},
+		{"issued with default", cert, func() { responder.SetDefaultStatus(CertStatus{Status: ocsp.Unknown}) }, ocsp.Revoked},
+	}
+	for _, test := range tests {
+		test.set()
+		response, err := queryResponder(t, responder, test.cert)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if response.Status != test.want {
+			t.Errorf("%s: status is %d; want %d", test.name, response.Status, test.want)
+		}
+	}
+	if requests := responder.Requests(); requests != len(tests) {
+		t.Errorf("responder received %d requests; want %d", requests, len(tests))
+	}
+}
+
+func TestResponderConcurrent(t *testing.T) {
+	responder, err := NewResponder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+	unissued := &x509.Certificate{SerialNumber: big.NewInt(1 << 40)}
+
+	// Changing the responder's behavior while it's answering queries must be safe
+	var (
+		queries sync.WaitGroup
+		setters sync.WaitGroup
+		done    = make(chan struct{})
+	)
+	for i := 0; i < 4; i++ {
+		queries.Add(1)
+		go func() {
+			defer queries.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := queryResponder(t, responder, unissued); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		setters.Add(1)
+		go func(i int) {
+			defer setters.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				responder.SetDefaultStatus(CertStatus{Status: (i + j) % 3})
+				responder.SetStatus(big.NewInt(int64(j%10)), CertStatus{Status: ocsp.Good})
+				responder.SetLatency(0)
+			}
+		}(i)
+	}
+	queries.Wait()
+	close(done)
+	setters.Wait()
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"software.sslmate.com/src/ocsputil/ocsptest"
+)
+
+// Return an [ocsptest.Responder] which is closed when the test finishes
+func newTestResponder(t *testing.T) *ocsptest.Responder {
+	t.Helper()
+	responder, err := ocsptest.NewResponder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(responder.Close)
+	return responder
+}
+
+// Issue a certificate from responder's CA, and return it along with a
+// [tls.Certificate] containing it, the CA, and its private key
+func issueTestCertificate(t *testing.T, responder *ocsptest.Responder) (*x509.Certificate, *tls.Certificate) {
+	t.Helper()
+	cert, key, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, &tls.Certificate{
+		Certificate: [][]byte{cert.Raw, responder.CA.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Parse the certificates in a PKCS#12 (PFX) file, such as a code signing or
+// S/MIME bundle, and return them as a chain ordered from the leaf towards the
+// root, which is suitable for passing to [CheckChain].  The leaf is the certificate
+// matching the bundle's private key, or if there is no key, the certificate
+// which doesn't issue any other certificate in the bundle.  Certificates which
+// aren't part of the leaf's chain are omitted.  The private key is not returned.
+//
+// Both the AES-based encryption used by current versions of OpenSSL and other
+// tools, and the legacy 3DES and RC2 encryption, can be decrypted.  A bundle
+// without a private key must be a trust store, such as one created by Java's keytool.
+func ParsePKCS12(data []byte, password string) ([]*x509.Certificate, error) {
+	var (
+		certs []*x509.Certificate
+		leaf  *x509.Certificate
+	)
+	privateKey, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err == nil {
+		certs = append([]*x509.Certificate{cert}, caCerts...)
+		if signer, ok := privateKey.(crypto.Signer); ok {
+			leaf = findKeyCertificate(signer.Public(), certs)
+		}
+	} else if trustStore, trustStoreErr := pkcs12.DecodeTrustStore(data, password); trustStoreErr == nil {
+		// A bundle without a private key
+		certs = trustStore
+	} else {
+		return nil, fmt.Errorf("unable to decode PKCS#12 data: %w", err)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("PKCS#12 data contains no certificates")
+	}
+
+	if leaf == nil {
+		leaf = findUnissuing(certs)
+	}
+	return buildChain(leaf, certs), nil
+}
+
+// Return the certificate in certs whose public key is publicKey, or nil if there isn't one
+func findKeyCertificate(publicKey crypto.PublicKey, certs []*x509.Certificate) *x509.Certificate {
+	key, ok := publicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return nil
+	}
+	for _, cert := range certs {
+		if key.Equal(cert.PublicKey) {
+			return cert
+		}
+	}
+	return nil
+}
+
+// Return the first certificate which isn't the issuer of any other certificate in certs
+func findUnissuing(certs []*x509.Certificate) *x509.Certificate {
+	for _, cert := range certs {
+		issues := false
+		for _, other := range certs {
+			if other != cert && bytes.Equal(other.RawIssuer, cert.RawSubject) {
+				issues = true
+				break
+			}
+		}
+		if !issues {
+			return cert
+		}
+	}
+	return certs[0]
+}
+
+// Return the chain starting with leaf, built by repeatedly appending the
+// certificate from certs which issued the previous one
+func buildChain(leaf *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	chain := []*x509.Certificate{leaf}
+	used := map[*x509.Certificate]bool{leaf: true}
+	for {
+		last := chain[len(chain)-1]
+		if bytes.Equal(last.RawIssuer, last.RawSubject) {
+			return chain
+		}
+		var next *x509.Certificate
+		for _, cert := range certs {
+			if !used[cert] && bytes.Equal(cert.RawSubject, last.RawIssuer) && last.CheckSignatureFrom(cert) == nil {
+				next = cert
+				break
+			}
+		}
+		if next == nil {
+			return chain
+		}
+		chain = append(chain, next)
+		used[next] = true
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestParsePKCS12(t *testing.T) {
+	responder := newTestResponder(t)
+	leaf, key, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		encoder *pkcs12.Encoder
+	}{
+		{"aes", pkcs12.Modern},
+		{"3des", pkcs12.LegacyDES},
+		{"rc2", pkcs12.LegacyRC2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := test.encoder.Encode(key, leaf, []*x509.Certificate{responder.CA}, "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			chain, err := ParsePKCS12(data, "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chain) != 2 || !chain[0].Equal(leaf) || !chain[1].Equal(responder.CA) {
+				t.Errorf("ParsePKCS12 returned the wrong chain")
+			}
+			if _, err := ParsePKCS12(data, "wrong"); err == nil {
+				t.Errorf("ParsePKCS12 succeeded with the wrong password")
+			}
+		})
+	}
+}
+
+func TestParsePKCS12WithoutKey(t *testing.T) {
+	responder := newTestResponder(t)
+	leaf, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a key, the leaf is identified as the certificate which doesn't
+	// issue the others, regardless of the order of the bundle
+	data, err := pkcs12.Modern.EncodeTrustStore([]*x509.Certificate{responder.CA, leaf}, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := ParsePKCS12(data, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || !chain[0].Equal(leaf) || !chain[1].Equal(responder.CA) {
+		t.Errorf("ParsePKCS12 returned the wrong chain")
+	}
+}
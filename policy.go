@@ -0,0 +1,94 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"time"
+)
+
+// Describes the rules that apply to OCSP responses produced on or after
+// a particular date.
+type Profile struct {
+	// The date on which these rules took effect.
+	Effective time.Time
+
+	// The maximum permitted validity interval, which runs from thisUpdate through
+	// nextUpdate inclusive, i.e. one second longer than their difference.
+	// Zero means there is no maximum.
+	MaxValidity time.Duration
+
+	// Whether responses must contain a nextUpdate field.
+	RequireNextUpdate bool
+
+	// Whether responses must not be signed using SHA-1.
+	ProhibitSHA1 bool
+}
+
+// The profiles used when [Config].Profiles is nil, derived from the Baseline
+// Requirements and root program policies.
+//
+// The first profile reflects Section 4.9.10 of the original Baseline Requirements,
+// which limited OCSP responses to a maximum expiration time of ten days.
+// Ballot SC31 (effective September 30, 2020) defined the validity interval
+// in terms of thisUpdate and nextUpdate, making nextUpdate mandatory.
+// Root programs prohibited SHA-1-signed OCSP responses starting June 1, 2022.
+var DefaultProfiles = []Profile{
+	{
+		Effective:   time.Time{},
+		MaxValidity: 10 * 24 * time.Hour,
+	},
+	{
+		Effective:         time.Date(2020, time.September, 30, 0, 0, 0, 0, time.UTC),
+		MaxValidity:       10 * 24 * time.Hour,
+		RequireNextUpdate: true,
+	},
+	{
+		Effective:         time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC),
+		MaxValidity:       10 * 24 * time.Hour,
+		RequireNextUpdate: true,
+		ProhibitSHA1:      true,
+	},
+}
+
+// Return the profile from profiles which was in effect at the given time,
+// i.e. the profile with the latest Effective date that is not after t.
+// Returns the zero Profile if no profile was in effect.
+func ProfileAt(profiles []Profile, t time.Time) Profile {
+	var (
+		found  bool
+		result Profile
+	)
+	for _, profile := range profiles {
+		if profile.Effective.After(t) {
+			continue
+		}
+		if !found || profile.Effective.After(result.Effective) {
+			result = profile
+			found = true
+		}
+	}
+	return result
+}
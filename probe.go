@@ -0,0 +1,372 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// The number of queries used to measure a responder's latency in [ProbeResponder]
+const probeLatencySamples = 5
+
+// The maximum number of sequential queries sent by the keep-alive probe in [ProbeResponder]
+const probeKeepAliveRequests = 10
+
+// The outcome of one check in a [CapabilityReport]
+type ProbeResult struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// The latency of a responder, as measured by [ProbeResponder] or [MirrorReport]
+type LatencyStats struct {
+	Samples int           `json:"samples"`
+	Min     time.Duration `json:"min"`
+	Median  time.Duration `json:"median"`
+	Max     time.Duration `json:"max"`
+}
+
+// A report of an OCSP responder's capabilities and compliance, as produced by
+// [ProbeResponder].  It can be serialized as JSON, e.g. for CA onboarding reviews.
+type CapabilityReport struct {
+	ResponderURL string    `json:"responder_url"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	ProbedAt     time.Time `json:"probed_at"`
+
+	POST           ProbeResult `json:"post"`            // A POST request with a SHA-1 CertID is answered
+	GET            ProbeResult `json:"get"`             // A GET request is answered
+	SHA256CertID   ProbeResult `json:"sha256_cert_id"`  // A request with a SHA-256 CertID is answered with a SHA-256 CertID
+	Nonce          ProbeResult `json:"nonce"`           // The nonce in a request is echoed in the response
+	CachingHeaders ProbeResult `json:"caching_headers"` // A GET response has the HTTP caching headers recommended by RFC 5019
+	UnissuedSerial ProbeResult `json:"unissued_serial"` // A serial number which was never issued is reported as unknown or revoked, or rejected as unauthorized or malformed
+	Redundancy     ProbeResult `json:"redundancy"`      // At least two of the responder's IP addresses work (see [ProbeFailover])
+	KeepAlive      ProbeResult `json:"keep_alive"`      // Sequential requests are served over a persistent connection
+
+	// The number of sequential requests served over one connection, up to 10,
+	// or 0 if the keep-alive probe failed.  Pipelining is not probed, since
+	// net/http does not support it.
+	RequestsPerConnection int `json:"requests_per_connection"`
+
+	Failover *FailoverReport `json:"failover"` // nil if the failover probe couldn't be run
+
+	Latency  LatencyStats      `json:"latency"`
+	Findings []Finding         `json:"findings"` // [Lint] findings for the response to the POST request
+	Vantage  map[string]string `json:"vantage"`
+}
+
+// Run a suite of probes against the OCSP responder for the given certificate
+// and return a report of the responder's capabilities and compliance.  The
+// probes test POST and GET requests, SHA-256 CertIDs, nonces, HTTP caching
+// headers, the response for a serial number which was never issued, redundancy
+// (using [ProbeFailover]), persistent connections, and latency.
+// Since the probes send more than a dozen queries, ProbeResponder is intended
+// for evaluating a single responder, not for scanning.
+//
+// The responder URL, timeouts, and other options are taken from config, but
+// config.Method, config.Nonce, config.StrictCertIDHash, and any
+// [IssuerOverride].Hash are ignored, since the probes control them.  If config
+// is nil, a zero-value [Config] is used, which provides sensible defaults.
+//
+// Returns an error if cert has no responder URL, or if ctx is done.  Failures
+// of individual probes are recorded in the report.
+func ProbeResponder(ctx context.Context, cert *x509.Certificate, issuerCert *x509.Certificate, config *Config) (*CapabilityReport, error) {
+	config = config.forIssuer(issuerCert)
+	serverURL, _ := config.responderURL(cert, issuerCert)
+	if serverURL == "" {
+		return nil, ErrNoResponder
+	}
+	if config != nil && config.ResponderIPOverride != nil && config.pinnedClient == nil {
+		// Share one pinned client across the probes, as EvaluateBatch does, so
+		// that the keep-alive probe can observe persistent connections; if it
+		// can't be created, each probe fails with the error instead
+		if pinned, err := pinnedClient(config.httpClient(), config.ResponderIPOverride); err == nil {
+			pinnedConfig := new(Config)
+			*pinnedConfig = *config
+			pinnedConfig.pinnedClient = pinned
+			config = pinnedConfig
+			defer pinned.CloseIdleConnections()
+		}
+	}
+
+	report := &CapabilityReport{
+		ResponderURL: serverURL,
+		Issuer:       issuerCert.Subject.String(),
+		SerialNumber: fmt.Sprintf("%X", cert.SerialNumber),
+		ProbedAt:     time.Now().UTC(),
+		Vantage:      config.vantage(),
+	}
+	p := &prober{ctx: ctx, serverURL: serverURL, issuerCert: issuerCert, config: config}
+
+	result, details, err := p.probe(cert, MethodPOST, crypto.SHA1, false)
+	if err != nil {
+		report.POST = ProbeResult{Detail: err.Error()}
+	} else {
+		report.POST = ProbeResult{OK: true, Detail: "status " + statusName(details.Status)}
+		report.Findings = lintFindings(cert, issuerCert, result.responseBytes, config)
+	}
+
+	result, _, err = p.probe(cert, MethodGET, crypto.SHA1, false)
+	if err != nil {
+		report.GET = ProbeResult{Detail: err.Error()}
+		report.CachingHeaders = ProbeResult{Detail: "GET request failed"}
+	} else {
+		report.GET = ProbeResult{OK: true}
+		report.CachingHeaders = checkCachingHeaders(result)
+	}
+
+	_, details, err = p.probe(cert, MethodPOST, crypto.SHA256, false)
+	if err != nil {
+		report.SHA256CertID = ProbeResult{Detail: err.Error()}
+	} else if details.IssuerHash != crypto.SHA256 {
+		report.SHA256CertID = ProbeResult{Detail: fmt.Sprintf("response uses a %s CertID", details.IssuerHash)}
+	} else {
+		report.SHA256CertID = ProbeResult{OK: true}
+	}
+
+	result, _, err = p.probe(cert, MethodPOST, crypto.SHA1, true)
+	if err != nil {
+		report.Nonce = ProbeResult{Detail: err.Error()}
+	} else if responseNonce(result.responseBytes) == nil {
+		report.Nonce = ProbeResult{Detail: "nonce not echoed"}
+	} else {
+		report.Nonce = ProbeResult{OK: true, Detail: "nonce echoed"}
+	}
+
+	report.UnissuedSerial, err = p.probeUnissued(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Failover, err = ProbeFailover(ctx, cert, issuerCert, config)
+	if err != nil {
+		report.Redundancy = ProbeResult{Detail: err.Error()}
+	} else {
+		report.Redundancy = ProbeResult{
+			OK:     report.Failover.Redundant(),
+			Detail: fmt.Sprintf("%d of %d addresses working, in %d networks", report.Failover.Working, len(report.Failover.Addresses), report.Failover.WorkingNetworks),
+		}
+	}
+
+	report.KeepAlive, report.RequestsPerConnection = p.probeKeepAlive(cert)
+	report.Latency = p.measureLatency(cert)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+type prober struct {
+	ctx        context.Context
+	serverURL  string
+	issuerCert *x509.Certificate
+	config     *Config
+}
+
+// Query the responder about cert using the given method, CertID hash, and nonce
+// setting, and verify the response
+func (p *prober) probe(cert *x509.Certificate, method Method, hash crypto.Hash, nonce bool) (result queryResult, details *ResponseDetails, err error) {
+	requestBytes, err := ocsp.CreateRequest(cert, p.issuerCert, &ocsp.RequestOptions{Hash: hash})
+	if err != nil {
+		return
+	}
+	if nonce {
+		if requestBytes, err = addNonce(requestBytes); err != nil {
+			return
+		}
+	}
+
+	probeConfig := new(Config)
+	if p.config != nil {
+		*probeConfig = *p.config
+	}
+	probeConfig.Method = method
+	probeConfig.StrictCertIDHash = false
+	if err = probeConfig.waitForResponder(p.ctx, p.serverURL); err != nil {
+		return
+	}
+	result, err = query(p.ctx, p.serverURL, requestBytes, probeConfig)
+	if err != nil {
+		return
+	}
+	details, err = checkResponseDetails(cert, p.issuerCert, result.responseBytes, probeConfig)
+	return
+}
+
+// Query the responder about a random serial number, which was almost certainly
+// never issued, and make sure it isn't reported as good (see Section 4.9.10 of the
+// Baseline Requirements).  The check passes only if the responder answers with
+// unknown or revoked, or the unauthorized or malformedRequest response status;
+// any other failure means the responder's behavior couldn't be determined.
+// Returns an error only if p.ctx is done.
+func (p *prober) probeUnissued(cert *x509.Certificate) (ProbeResult, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 159))
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	unissued := &x509.Certificate{SerialNumber: serial}
+
+	_, details, err := p.probe(unissued, MethodPOST, crypto.SHA1, false)
+	var responseErr ocsp.ResponseError
+	switch {
+	case p.ctx.Err() != nil:
+		return ProbeResult{}, p.ctx.Err()
+	case errors.Is(err, ErrUnknown):
+		return ProbeResult{OK: true, Detail: "status unknown"}, nil
+	case errors.As(err, &responseErr) && (responseErr.Status == ocsp.Unauthorized || responseErr.Status == ocsp.Malformed):
+		return ProbeResult{OK: true, Detail: responseErr.Error()}, nil
+	case err != nil:
+		// The responder didn't answer properly, so whether it would report the serial as good is unknown
+		return ProbeResult{Detail: err.Error()}, nil
+	case details.Revoked():
+		return ProbeResult{OK: true, Detail: "status revoked"}, nil
+	default:
+		return ProbeResult{Detail: "status good"}, nil
+	}
+}
+
+// Send sequential POST queries about cert, and count how many are served over
+// the connection used by the first, stopping at probeKeepAliveRequests
+func (p *prober) probeKeepAlive(cert *x509.Certificate) (ProbeResult, int) {
+	var (
+		mu   sync.Mutex
+		conn net.Conn
+	)
+	traced := *p
+	traced.ctx = httptrace.WithClientTrace(p.ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			conn = info.Conn
+		},
+	})
+
+	var (
+		firstConn net.Conn
+		count     int
+	)
+	for count < probeKeepAliveRequests {
+		if _, _, err := traced.probe(cert, MethodPOST, crypto.SHA1, false); err != nil {
+			if count == 0 {
+				return ProbeResult{Detail: err.Error()}, 0
+			}
+			break
+		}
+		mu.Lock()
+		usedConn := conn
+		mu.Unlock()
+		if firstConn == nil {
+			firstConn = usedConn
+		} else if usedConn != firstConn {
+			break
+		}
+		count++
+	}
+
+	switch {
+	case count == 1:
+		return ProbeResult{Detail: "connection closed after every request"}, count
+	case count == probeKeepAliveRequests:
+		return ProbeResult{OK: true, Detail: fmt.Sprintf("at least %d sequential requests served on one connection", count)}, count
+	default:
+		return ProbeResult{OK: true, Detail: fmt.Sprintf("%d sequential requests served on one connection", count)}, count
+	}
+}
+
+// Measure the latency of successful POST queries about cert
+func (p *prober) measureLatency(cert *x509.Certificate) LatencyStats {
+	var samples []time.Duration
+	for i := 0; i < probeLatencySamples && p.ctx.Err() == nil; i++ {
+		if result, _, err := p.probe(cert, MethodPOST, crypto.SHA1, false); err == nil {
+			samples = append(samples, result.responseTime)
+		}
+	}
+	return latencyStats(samples)
+}
+
+// Summarize the given latency samples, which are sorted in place
+func latencyStats(samples []time.Duration) LatencyStats {
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return LatencyStats{
+		Samples: len(samples),
+		Min:     samples[0],
+		Median:  samples[len(samples)/2],
+		Max:     samples[len(samples)-1],
+	}
+}
+
+// Check for the HTTP caching headers recommended by Section 6.2 of RFC 5019
+func checkCachingHeaders(result queryResult) ProbeResult {
+	var present, missing []string
+	for _, name := range []string{"Cache-Control", "Expires", "Last-Modified", "ETag"} {
+		if result.header.Get(name) != "" {
+			present = append(present, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	var b strings.Builder
+	if len(present) > 0 {
+		fmt.Fprintf(&b, "present: %s", strings.Join(present, ", "))
+	}
+	if len(missing) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "missing: %s", strings.Join(missing, ", "))
+	}
+	cacheable := strings.Contains(result.header.Get("Cache-Control"), "max-age") || result.header.Get("Expires") != ""
+	return ProbeResult{OK: cacheable, Detail: b.String()}
+}
+
+func statusName(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
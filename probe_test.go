@@ -0,0 +1,114 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"golang.org/x/crypto/ocsp"
+
+	"software.sslmate.com/src/ocsputil/ocsptest"
+)
+
+func TestProbeUnissued(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(*ocsptest.Responder)
+		wantOK bool
+	}{
+		{"unknown", func(*ocsptest.Responder) {}, true},
+		{"revoked", func(r *ocsptest.Responder) { r.SetDefaultStatus(ocsptest.CertStatus{Status: ocsp.Revoked}) }, true},
+		{"unauthorized", func(r *ocsptest.Responder) { r.SetRawResponse(ocsp.UnauthorizedErrorResponse) }, true},
+		{"malformed", func(r *ocsptest.Responder) { r.SetRawResponse(ocsp.MalformedRequestErrorResponse) }, true},
+		{"good", func(r *ocsptest.Responder) { r.SetDefaultStatus(ocsptest.CertStatus{Status: ocsp.Good}) }, false},
+		{"try later", func(r *ocsptest.Responder) { r.SetRawResponse(ocsp.TryLaterErrorResponse) }, false},
+		{"internal error", func(r *ocsptest.Responder) { r.SetRawResponse(ocsp.InternalErrorErrorResponse) }, false},
+		{"http error", func(r *ocsptest.Responder) { r.SetHTTPError(http.StatusInternalServerError) }, false},
+		{"garbage", func(r *ocsptest.Responder) { r.SetRawResponse([]byte("garbage")) }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			responder := newTestResponder(t)
+			cert, _, err := responder.IssueCertificate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.setup(responder)
+
+			p := &prober{ctx: context.Background(), serverURL: responder.URL, issuerCert: responder.CA}
+			result, err := p.probeUnissued(cert)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.OK != test.wantOK {
+				t.Errorf("OK = %v (%s); want %v", result.OK, result.Detail, test.wantOK)
+			}
+			if result.Detail == "" {
+				t.Errorf("Detail is empty")
+			}
+		})
+	}
+}
+
+func TestProbeUnissuedCanceled(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := &prober{ctx: ctx, serverURL: responder.URL, issuerCert: responder.CA}
+	if _, err := p.probeUnissued(cert); err == nil {
+		t.Errorf("probeUnissued succeeded despite canceled context")
+	}
+}
+
+func TestProbeResponderIPOverride(t *testing.T) {
+	responder := newTestResponder(t)
+	cert, _, err := responder.IssueCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Persistent connections must be observable even though every query is
+	// pinned to an IP address
+	config := &Config{ResponderIPOverride: net.IPv4(127, 0, 0, 1)}
+	report, err := ProbeResponder(context.Background(), cert, responder.CA, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.POST.OK {
+		t.Errorf("POST probe failed: %s", report.POST.Detail)
+	}
+	if !report.KeepAlive.OK || report.RequestsPerConnection != probeKeepAliveRequests {
+		t.Errorf("keep-alive probe reported %d requests per connection (%s); want %d", report.RequestsPerConnection, report.KeepAlive.Detail, probeKeepAliveRequests)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A pre-filled Certificate Problem Report describing an OCSP failure, suitable for
+// sending to the CA's problem reporting address (see Section 4.9.3 of the Baseline
+// Requirements).  It can be serialized as JSON, or rendered as an email body using
+// [ProblemReport.EmailBody].
+type ProblemReport struct {
+	Subject       string            `json:"subject"`
+	Issuer        string            `json:"issuer"`
+	SerialNumber  string            `json:"serial_number"`
+	Certificate   string            `json:"certificate"`
+	ResponderURL  *string           `json:"responder_url"`
+	Method        *string           `json:"method"`
+	Problem       string            `json:"problem"`
+	ObservedAt    time.Time         `json:"observed_at"`
+	ResponseTime  time.Duration     `json:"response_time"`
+	RequestBytes  []byte            `json:"request_bytes"`
+	ResponseBytes []byte            `json:"response_bytes"`
+	Vantage       map[string]string `json:"vantage"`
+}
+
+// Encode the report as JSON, with ResponseTime formatted as a duration string
+// such as "1.5s", like the response_time in evalocsp's output
+func (report ProblemReport) MarshalJSON() ([]byte, error) {
+	type plainReport ProblemReport
+	return json.Marshal(struct {
+		plainReport
+		ResponseTime string `json:"response_time"`
+	}{plainReport(report), report.ResponseTime.String()})
+}
+
+// Report whether an error with the given code is the OCSP responder's fault,
+// as opposed to a problem with the certificate, the local network, or the
+// evaluation itself
+func isResponderFault(code ErrorCode) bool {
+	switch code {
+	case ErrorCodeTimeout,
+		ErrorCodeConnection,
+		ErrorCodeRead,
+		ErrorCodeHTTPStatus,
+		ErrorCodeContentType,
+		ErrorCodeResponderStatus,
+		ErrorCodeResponseInvalid,
+		ErrorCodeResponseSHA1,
+		ErrorCodeNonceMismatch,
+		ErrorCodeCertIDHashMismatch,
+		ErrorCodeUnknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Given a certificate, the result of evaluating it with [Evaluate], and the time at which
+// the evaluation was performed, return a problem report containing the evidence of the failure.
+//
+// Returns an error if the certificate can't be parsed, if eval does not contain an error,
+// or if the error isn't the OCSP responder's fault according to its [ErrorCode] (e.g.
+// the certificate has no responder, the responder's hostname couldn't be resolved,
+// or the evaluation was canceled).
+func NewProblemReport(certData []byte, eval Evaluation, observedAt time.Time) (*ProblemReport, error) {
+	if eval.Err == nil {
+		return nil, errors.New("evaluation was successful, so there is no problem to report")
+	}
+	if code := ErrorCodeOf(eval.Err); !isResponderFault(code) {
+		return nil, fmt.Errorf("evaluation failed with error code %s, which is not a problem with the OCSP responder", code)
+	}
+	cert, err := x509.ParseCertificate(certData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %w", err)
+	}
+	return &ProblemReport{
+		Subject:       cert.Subject.String(),
+		Issuer:        cert.Issuer.String(),
+		SerialNumber:  fmt.Sprintf("%X", cert.SerialNumber),
+		Certificate:   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certData})),
+		ResponderURL:  eval.ResponderURL,
+		Method:        eval.Method,
+		Problem:       eval.Err.Error(),
+		ObservedAt:    observedAt.UTC(),
+		ResponseTime:  eval.ResponseTime,
+		RequestBytes:  eval.RequestBytes,
+		ResponseBytes: eval.ResponseBytes,
+		Vantage:       eval.Vantage,
+	}, nil
+}
+
+// Render the problem report as a plain text email body.
+func (report *ProblemReport) EmailBody() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The OCSP responder for the following certificate did not provide a valid response.\n\n")
+	fmt.Fprintf(&b, "Subject: %s\n", report.Subject)
+	fmt.Fprintf(&b, "Issuer: %s\n", report.Issuer)
+	fmt.Fprintf(&b, "Serial Number: %s\n", report.SerialNumber)
+	if report.ResponderURL != nil {
+		fmt.Fprintf(&b, "OCSP Responder: %s\n", *report.ResponderURL)
+	}
+	if report.Method != nil {
+		fmt.Fprintf(&b, "HTTP Method: %s\n", *report.Method)
+	}
+	fmt.Fprintf(&b, "Observed At: %s\n", report.ObservedAt.Format(time.RFC3339))
+	if report.ResponseTime != 0 {
+		fmt.Fprintf(&b, "Response Time: %s\n", report.ResponseTime)
+	}
+	fmt.Fprintf(&b, "Problem: %s\n", report.Problem)
+	if len(report.Vantage) > 0 {
+		keys := make([]string, 0, len(report.Vantage))
+		for key := range report.Vantage {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(&b, "\nVantage Point:\n")
+		for _, key := range keys {
+			fmt.Fprintf(&b, "  %s: %s\n", key, report.Vantage[key])
+		}
+	}
+	if report.RequestBytes != nil {
+		fmt.Fprintf(&b, "\nOCSP Request (base64):\n%s\n", base64.StdEncoding.EncodeToString(report.RequestBytes))
+	}
+	if report.ResponseBytes != nil {
+		fmt.Fprintf(&b, "\nOCSP Response (base64):\n%s\n", base64.StdEncoding.EncodeToString(report.ResponseBytes))
+	}
+	fmt.Fprintf(&b, "\nCertificate:\n%s", report.Certificate)
+	return b.String()
+}
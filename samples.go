@@ -0,0 +1,75 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"sync"
+)
+
+// The most recent samples of some measurement for each OCSP responder, keyed
+// by responderKey.  Once a responder has window samples, each new sample
+// replaces the oldest.  The zero value is ready to use.
+type responderSamples[T any] struct {
+	mu        sync.Mutex
+	responder map[string]*sampleWindow[T]
+}
+
+type sampleWindow[T any] struct {
+	values []T
+	next   int
+}
+
+func (samples *responderSamples[T]) add(key string, value T, window int) {
+	samples.mu.Lock()
+	defer samples.mu.Unlock()
+
+	if samples.responder == nil {
+		samples.responder = make(map[string]*sampleWindow[T])
+	}
+	w := samples.responder[key]
+	if w == nil {
+		w = new(sampleWindow[T])
+		samples.responder[key] = w
+	}
+	if len(w.values) < window {
+		w.values = append(w.values, value)
+	} else {
+		w.values[w.next] = value
+		w.next = (w.next + 1) % len(w.values)
+	}
+}
+
+// Return a copy of the samples for the given responder, in no particular order
+func (samples *responderSamples[T]) get(key string) []T {
+	samples.mu.Lock()
+	defer samples.mu.Unlock()
+
+	if w := samples.responder[key]; w != nil {
+		return append([]T(nil), w.values...)
+	} else {
+		return nil
+	}
+}
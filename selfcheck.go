@@ -0,0 +1,158 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrUnhealthy is returned by [SelfCheck] when the monitoring host appears unable to evaluate OCSP responders reliably
+var ErrUnhealthy = errors.New("Monitoring host appears unhealthy")
+
+// The maximum discrepancy between the local clock and a reference response's
+// validity period that [SelfCheck] tolerates.
+const ClockTolerance = 5 * time.Minute
+
+// A certificate with a known-good OCSP responder, used by [SelfCheck].
+// The fields have the same meaning as the arguments to [Evaluate].
+type Reference struct {
+	CertData      []byte
+	IssuerSubject []byte
+	IssuerPubkey  []byte
+}
+
+// Represents the result of [SelfCheck].  Evaluations and Problems have one
+// entry per reference, in the order the references were passed to SelfCheck.
+// A nil entry in Problems means the reference was checked successfully.
+// Err is nil if the monitoring host appears healthy, and wraps [ErrUnhealthy] otherwise.
+type SelfCheckResult struct {
+	Evaluations []Evaluation
+	Problems    []error
+	Err         error
+}
+
+// Given a list of certificates with known-good OCSP responders, evaluate each
+// of them to determine if the monitoring host is healthy enough to produce
+// meaningful results.  For each reference, SelfCheck verifies that the responder's
+// hostname resolves, that [Evaluate] succeeds, and that the local clock falls
+// within the response's validity period (allowing for [ClockTolerance]).
+// [Config].Cache is not used, so that every reference is checked over the network.
+// The hostname isn't resolved locally if queries go through an HTTP proxy (which
+// resolves it instead) or [Config].ResponderIPOverride is set.
+//
+// Since the references are known to be good, failures are attributed to the
+// monitoring host.  The host is considered unhealthy if more than half of
+// the references have problems.  Callers can then refuse to start a scan,
+// or annotate its results.
+//
+// If config is nil, a zero-value [Config] is used, which provides
+// sensible defaults.
+func SelfCheck(ctx context.Context, references []Reference, config *Config) (result SelfCheckResult) {
+	if len(references) == 0 {
+		result.Err = fmt.Errorf("%w: no references provided", ErrUnhealthy)
+		return
+	}
+
+	uncachedConfig := new(Config)
+	if config != nil {
+		*uncachedConfig = *config
+	}
+	uncachedConfig.Cache = nil
+
+	result.Evaluations = make([]Evaluation, len(references))
+	result.Problems = make([]error, len(references))
+	numProblems := 0
+	for i, ref := range references {
+		eval := Evaluate(ctx, ref.CertData, ref.IssuerSubject, ref.IssuerPubkey, uncachedConfig)
+		result.Evaluations[i] = eval
+		result.Problems[i] = checkReference(ctx, eval, uncachedConfig)
+		if result.Problems[i] != nil {
+			numProblems++
+		}
+	}
+
+	if numProblems*2 > len(references) {
+		result.Err = fmt.Errorf("%w: %d of %d references have problems", ErrUnhealthy, numProblems, len(references))
+	}
+	return
+}
+
+func checkReference(ctx context.Context, eval Evaluation, config *Config) error {
+	if eval.ResponderURL != nil && config.resolvesLocally(*eval.ResponderURL) {
+		if parsedURL, err := url.Parse(*eval.ResponderURL); err == nil {
+			if _, err := net.DefaultResolver.LookupHost(ctx, parsedURL.Hostname()); err != nil {
+				return fmt.Errorf("unable to resolve OCSP responder hostname: %w", err)
+			}
+		}
+	}
+
+	if eval.Err != nil {
+		return eval.Err
+	}
+
+	response := eval.Response
+	now := time.Now()
+	if response.ThisUpdate.After(now.Add(ClockTolerance)) {
+		return fmt.Errorf("local clock is behind: response thisUpdate is %s", response.ThisUpdate)
+	}
+	if !response.NextUpdate.IsZero() && response.NextUpdate.Before(now.Add(-ClockTolerance)) {
+		return fmt.Errorf("local clock is ahead: response nextUpdate is %s", response.NextUpdate)
+	}
+	return nil
+}
+
+// Report whether a query to serverURL resolves the responder's hostname using
+// the local resolver, rather than connecting to [Config].ResponderIPOverride or
+// going through an HTTP proxy.  Returns false if HTTPClient's transport isn't
+// an [*http.Transport], since it's unknown how it connects.
+func (config *Config) resolvesLocally(serverURL string) bool {
+	if config != nil && config.ResponderIPOverride != nil {
+		return false
+	}
+	transport := config.httpClient().Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	httpTransport, ok := transport.(*http.Transport)
+	if !ok {
+		return false
+	}
+	if httpTransport.Proxy == nil {
+		return true
+	}
+	httpRequest, err := http.NewRequest(http.MethodPost, serverURL, nil)
+	if err != nil {
+		return true
+	}
+	proxyURL, err := httpTransport.Proxy(httpRequest)
+	return err == nil && proxyURL == nil
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"sort"
+	"time"
+)
+
+// Tracks the age of each OCSP responder's responses when they are received
+// (the local time minus the response's producedAt time), so that responders
+// with wrong clocks can be told apart from responders which pre-generate
+// their responses.  Responders are identified by the host of their URL.
+//
+// A response can't be received before it's produced, so a negative age means
+// the responder's clock is ahead of the local clock.  A responder whose clock
+// is behind produces ages which are consistently large, even for responses
+// produced hours apart, whereas the ages of pre-generated responses depend on
+// how long before they were fetched they were generated.
+//
+// The zero value is ready to use and provides sensible defaults.  A SkewTracker
+// is safe for concurrent use, and can be shared by multiple [Config]s.
+type SkewTracker struct {
+	// The number of most recent distinct responses retained for each responder.  If zero, 1000 is used.
+	Window int
+
+	samples responderSamples[ageSample]
+}
+
+type ageSample struct {
+	producedAt time.Time
+	age        time.Duration
+}
+
+// The distribution of a responder's response ages, as returned by [SkewTracker].Stats
+type SkewStats struct {
+	Samples   int // The number of distinct responses observed
+	MinAge    time.Duration
+	MedianAge time.Duration
+	MaxAge    time.Duration
+
+	// The time between the earliest and latest producedAt times of the responses
+	ProducedSpan time.Duration
+}
+
+// The number of distinct responses required before [SkewStats].Drift attributes
+// consistently large ages to the responder's clock being behind
+const skewMinResponses = 10
+
+func (tracker *SkewTracker) window() int {
+	if tracker.Window != 0 {
+		return tracker.Window
+	} else {
+		return 1000
+	}
+}
+
+// Record that a response produced at producedAt was received from the
+// responder at serverURL at receivedAt.  [CheckCert], [Evaluate], and [Stapler]
+// call this automatically when [Config].SkewTracker is set.
+//
+// Responses are identified by their producedAt time, and only the first receipt
+// of each response is recorded, so that querying the same certificate repeatedly
+// doesn't skew the statistics with the growing age of a single response.
+func (tracker *SkewTracker) Observe(serverURL string, producedAt time.Time, receivedAt time.Time) {
+	key := responderKey(serverURL)
+	for _, sample := range tracker.samples.get(key) {
+		if sample.producedAt.Equal(producedAt) {
+			return
+		}
+	}
+	tracker.samples.add(key, ageSample{producedAt: producedAt, age: receivedAt.Sub(producedAt)}, tracker.window())
+}
+
+// Return the distribution of response ages observed for the responder at serverURL.
+// Samples is zero if no responses from the responder have been observed.
+func (tracker *SkewTracker) Stats(serverURL string) SkewStats {
+	samples := tracker.samples.get(responderKey(serverURL))
+	if len(samples) == 0 {
+		return SkewStats{}
+	}
+
+	ages := make([]time.Duration, len(samples))
+	earliest, latest := samples[0].producedAt, samples[0].producedAt
+	for i, sample := range samples {
+		ages[i] = sample.age
+		if sample.producedAt.Before(earliest) {
+			earliest = sample.producedAt
+		}
+		if sample.producedAt.After(latest) {
+			latest = sample.producedAt
+		}
+	}
+	sort.Slice(ages, func(i, j int) bool { return ages[i] < ages[j] })
+	return SkewStats{
+		Samples:      len(ages),
+		MinAge:       ages[0],
+		MedianAge:    ages[len(ages)/2],
+		MaxAge:       ages[len(ages)-1],
+		ProducedSpan: latest.Sub(earliest),
+	}
+}
+
+// Estimate how far the responder's clock is ahead of the local clock (negative
+// if it's behind).  If the youngest response was received before it was produced,
+// the clock is ahead by at least that much.
+//
+// Large ages are normally attributed to pre-generation, even if they're
+// consistent, since a batch of responses generated at once and fetched soon
+// after one another all have similar ages.  The clock is only assumed to be
+// behind if there are at least 10 distinct responses, every one of which was
+// older than [ClockTolerance], their ages vary by no more than ClockTolerance,
+// and they were produced over a longer period than their age, which suggests
+// they were generated on demand.  Otherwise, the estimate is zero.
+func (stats SkewStats) Drift() time.Duration {
+	switch {
+	case stats.Samples == 0:
+		return 0
+	case stats.MinAge < 0:
+		return -stats.MinAge
+	case stats.Samples >= skewMinResponses &&
+		stats.MinAge > ClockTolerance &&
+		stats.MaxAge-stats.MinAge <= ClockTolerance &&
+		stats.ProducedSpan > stats.MaxAge:
+		return -stats.MinAge
+	default:
+		return 0
+	}
+}
+
+// Report whether the responder's clock appears to be wrong by more than [ClockTolerance]
+func (stats SkewStats) ClockSkewed() bool {
+	drift := stats.Drift()
+	return drift > ClockTolerance || drift < -ClockTolerance
+}
@@ -0,0 +1,409 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	stapleMinRetryDelay     = 1 * time.Minute
+	stapleMaxRetryDelay     = 1 * time.Hour
+	stapleMinRefreshDelay   = 5 * time.Minute
+	stapleNoNextUpdateDelay = 1 * time.Hour
+)
+
+// Keeps a fresh OCSP staple for each of a set of TLS certificates.  For each
+// certificate added with [Stapler.Add], a background goroutine queries the OCSP
+// responder, validates the response, and refreshes it halfway through its
+// validity interval (with jitter to avoid synchronized refreshes).  Failed
+// refreshes are retried with exponential backoff, and the previous staple continues
+// to be served until its nextUpdate time passes.
+//
+// The current staples are available from [Stapler.GetCertificate], which is suitable
+// for use as [crypto/tls.Config].GetCertificate, or from [Stapler.Staple].
+//
+// To ensure a staple is available as soon as a renewed certificate is deployed,
+// pass it to [Stapler.Prewarm] when it is issued, then pass it to [Stapler.Add]
+// and the old certificate to [Stapler.Remove] when it is deployed, or use
+// [Stapler.Replace], which removes superseded certificates automatically.
+//
+// The zero value is ready to use.  A Stapler is safe for concurrent use.  Call
+// [Stapler.Close] to stop the background goroutines.
+type Stapler struct {
+	// The configuration used for OCSP queries.  If nil, a zero-value [Config] is used.
+	Config *Config
+
+	// If non-nil, called after every attempt to refresh a staple.  details is
+	// non-nil if a response was obtained, and err is non-nil if the attempt failed.
+	// It may be called concurrently from multiple goroutines.
+	OnRefresh func(leaf *x509.Certificate, details *ResponseDetails, err error)
+
+	mu      sync.Mutex
+	staples []*staple
+	ctx     context.Context
+	cancel  context.CancelFunc
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type staple struct {
+	leaf       *x509.Certificate
+	issuerCert *x509.Certificate
+	cancel     context.CancelFunc // stops the staple's goroutine
+
+	// Protected by Stapler.mu
+	cert          *tls.Certificate
+	staged        bool // added by Prewarm and not yet by Add, so not served by GetCertificate
+	responseBytes []byte
+	nextUpdate    time.Time
+}
+
+// ErrStaplerClosed is returned when adding a certificate to a [Stapler] which has been closed
+var ErrStaplerClosed = errors.New("Stapler has been closed")
+
+// Start keeping a fresh OCSP staple for cert, which must contain the leaf
+// certificate followed by its issuer.  The first OCSP query is performed in
+// the background, so the staple may not be available immediately, unless the
+// leaf certificate was previously passed to [Stapler.Prewarm].
+//
+// If the leaf certificate has already been added, cert takes the place of the
+// previously-added [crypto/tls.Certificate] value, and the existing staple is kept.
+//
+// Returns an error if cert does not contain a parsable leaf and issuer, or if
+// the Stapler has been closed.
+func (stapler *Stapler) Add(cert *tls.Certificate) error {
+	return stapler.add(cert, false)
+}
+
+// Like [Stapler.Add], but for a certificate which has not been deployed yet,
+// such as a renewal staged by an ACME client.  The staple is obtained and kept
+// fresh in the background, but [Stapler.GetCertificate] doesn't serve the
+// certificate until it is passed to Add, at which point the staple is already
+// available.  Add may be passed a different [crypto/tls.Certificate] value, as
+// long as its leaf certificate is the same.
+//
+// Does nothing if the leaf certificate has already been added or prewarmed.
+func (stapler *Stapler) Prewarm(cert *tls.Certificate) error {
+	return stapler.add(cert, true)
+}
+
+func (stapler *Stapler) add(cert *tls.Certificate, staged bool) error {
+	if len(cert.Certificate) < 2 {
+		return errors.New("certificate chain must contain the leaf and its issuer")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("unable to parse certificate: %w", err)
+	}
+	issuerCert, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return fmt.Errorf("unable to parse issuer certificate: %w", err)
+	}
+
+	stapler.mu.Lock()
+	defer stapler.mu.Unlock()
+	if stapler.closed {
+		return ErrStaplerClosed
+	}
+	for i, st := range stapler.staples {
+		if !bytes.Equal(st.leaf.Raw, leaf.Raw) {
+			continue
+		}
+		if staged {
+			return nil
+		}
+		if st.staged {
+			// Promote the prewarmed staple, moving it to the end so that
+			// GetCertificate prefers certificates in the order they were added
+			st.cert = cert
+			st.staged = false
+			stapler.staples = append(append(stapler.staples[:i:i], stapler.staples[i+1:]...), st)
+			return nil
+		}
+		// Already added, so keep the existing staple and its goroutine
+		st.cert = cert
+		return nil
+	}
+	if stapler.ctx == nil {
+		stapler.ctx, stapler.cancel = context.WithCancel(context.Background())
+	}
+	ctx, cancel := context.WithCancel(stapler.ctx)
+	st := &staple{cert: cert, leaf: leaf, issuerCert: issuerCert, cancel: cancel, staged: staged}
+	stapler.staples = append(stapler.staples, st)
+	stapler.wg.Add(1)
+	go stapler.run(ctx, st)
+	return nil
+}
+
+// Like [Stapler.Add], but also remove the certificates which cert supersedes:
+// previously added certificates for the same set of DNS names and IP addresses,
+// with the same public key algorithm, and an earlier notBefore time.  Replace is
+// intended to be called after a certificate is renewed, and keeps certificates
+// with different key types (e.g. ECDSA and RSA certificates for the same names)
+// side by side.
+func (stapler *Stapler) Replace(cert *tls.Certificate) error {
+	if err := stapler.Add(cert); err != nil {
+		return err
+	}
+
+	stapler.mu.Lock()
+	defer stapler.mu.Unlock()
+
+	i := stapler.indexOf(cert)
+	if i < 0 {
+		return nil // removed concurrently
+	}
+	added := stapler.staples[i]
+	kept := stapler.staples[:0:0]
+	for _, st := range stapler.staples {
+		if st != added && !st.staged && supersedes(added.leaf, st.leaf) {
+			st.cancel()
+		} else {
+			kept = append(kept, st)
+		}
+	}
+	stapler.staples = kept
+	return nil
+}
+
+// Parse a PEM-encoded certificate chain (leaf first) and private key, and pass
+// them to [Stapler.Replace].  This is designed to be called from an ACME client
+// after a certificate is issued or renewed, with the PEM data which ACME
+// clients typically produce.  Returns the parsed certificate, which can also be
+// used for other purposes.
+func (stapler *Stapler) ReplacePEM(chainPEM []byte, keyPEM []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(chainPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if err := stapler.Replace(&cert); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// Return true if newLeaf is a renewal of oldLeaf
+func supersedes(newLeaf *x509.Certificate, oldLeaf *x509.Certificate) bool {
+	return newLeaf.PublicKeyAlgorithm == oldLeaf.PublicKeyAlgorithm &&
+		oldLeaf.NotBefore.Before(newLeaf.NotBefore) &&
+		sameStrings(certNames(newLeaf), certNames(oldLeaf))
+}
+
+// Return the sorted DNS names and IP addresses of cert
+func certNames(cert *x509.Certificate) []string {
+	names := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the index of the staple for cert's leaf certificate, or -1 if there
+// is none.  Staples are identified by leaf certificate rather than by
+// [crypto/tls.Certificate] value, since Add and Prewarm may be passed different
+// values for the same leaf.  stapler.mu must be held.
+func (stapler *Stapler) indexOf(cert *tls.Certificate) int {
+	if len(cert.Certificate) == 0 {
+		return -1
+	}
+	for i, st := range stapler.staples {
+		if bytes.Equal(st.leaf.Raw, cert.Certificate[0]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Stop keeping a staple for cert, whose leaf certificate must have been previously
+// passed to [Stapler.Add] or [Stapler.Prewarm].  Does nothing if the leaf isn't in the Stapler.
+func (stapler *Stapler) Remove(cert *tls.Certificate) {
+	stapler.mu.Lock()
+	defer stapler.mu.Unlock()
+
+	if i := stapler.indexOf(cert); i >= 0 {
+		stapler.staples[i].cancel()
+		stapler.staples = append(stapler.staples[:i:i], stapler.staples[i+1:]...)
+	}
+}
+
+// Stop refreshing staples and wait for the background goroutines to exit.
+// The staples obtained so far remain available until they expire.
+func (stapler *Stapler) Close() {
+	stapler.mu.Lock()
+	stapler.closed = true
+	if stapler.cancel != nil {
+		stapler.cancel()
+	}
+	stapler.mu.Unlock()
+
+	stapler.wg.Wait()
+}
+
+// Return the current staple for cert, whose leaf certificate must have been previously
+// passed to [Stapler.Add] or [Stapler.Prewarm].  Returns nil if no unexpired staple is available.
+func (stapler *Stapler) Staple(cert *tls.Certificate) []byte {
+	stapler.mu.Lock()
+	defer stapler.mu.Unlock()
+
+	if i := stapler.indexOf(cert); i >= 0 {
+		return stapler.staples[i].currentStaple(time.Now())
+	}
+	return nil
+}
+
+// Return a copy of the first added certificate that is supported by the client,
+// with its OCSPStaple field set to the current staple.  If the client doesn't
+// support any of the certificates, the first one is returned.  GetCertificate
+// is suitable for use as [crypto/tls.Config].GetCertificate.
+func (stapler *Stapler) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	stapler.mu.Lock()
+	defer stapler.mu.Unlock()
+
+	var selected *staple
+	for _, st := range stapler.staples {
+		if st.staged {
+			continue
+		}
+		if selected == nil {
+			selected = st
+		}
+		if hello.SupportsCertificate(st.cert) == nil {
+			selected = st
+			break
+		}
+	}
+	if selected == nil {
+		return nil, errors.New("Stapler contains no certificates")
+	}
+	cert := *selected.cert
+	cert.OCSPStaple = selected.currentStaple(time.Now())
+	return &cert, nil
+}
+
+func (st *staple) currentStaple(now time.Time) []byte {
+	if st.responseBytes == nil || (!st.nextUpdate.IsZero() && now.After(st.nextUpdate)) {
+		return nil
+	}
+	return st.responseBytes
+}
+
+func (stapler *Stapler) run(ctx context.Context, st *staple) {
+	defer stapler.wg.Done()
+
+	retryDelay := stapleMinRetryDelay
+	for {
+		var delay time.Duration
+		if refreshAt, err := stapler.refresh(ctx, st); err == nil {
+			retryDelay = stapleMinRetryDelay
+			delay = time.Until(refreshAt)
+		} else {
+			delay = retryDelay
+			retryDelay *= 2
+			if retryDelay > stapleMaxRetryDelay {
+				retryDelay = stapleMaxRetryDelay
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Obtain a new staple and return when it should next be refreshed
+func (stapler *Stapler) refresh(ctx context.Context, st *staple) (refreshAt time.Time, err error) {
+	var details *ResponseDetails
+	defer func() {
+		if stapler.OnRefresh != nil && ctx.Err() == nil {
+			stapler.OnRefresh(st.leaf, details, err)
+		}
+	}()
+
+	config := stapler.Config.forIssuer(st.issuerCert)
+	serverURL, requestBytes, err := createRequest(st.leaf, st.issuerCert, config)
+	if err != nil {
+		return
+	}
+	result, err := query(ctx, serverURL, requestBytes, config)
+	if err != nil {
+		return
+	}
+	responseBytes := result.responseBytes
+	details, err = checkResponseDetails(st.leaf, st.issuerCert, responseBytes, config)
+	if err != nil {
+		return
+	}
+	config.observeSkew(serverURL, details)
+
+	stapler.mu.Lock()
+	st.responseBytes = responseBytes
+	st.nextUpdate = details.NextUpdate
+	stapler.mu.Unlock()
+
+	return stapleRefreshTime(details, time.Now()), nil
+}
+
+func stapleRefreshTime(details *ResponseDetails, now time.Time) time.Time {
+	var refreshAt time.Time
+	if details.NextUpdate.IsZero() {
+		refreshAt = now.Add(stapleNoNextUpdateDelay)
+	} else {
+		validity := details.NextUpdate.Sub(details.ThisUpdate)
+		jitter := time.Duration((rand.Float64() - 0.5) * 0.2 * float64(validity))
+		refreshAt = details.ThisUpdate.Add(validity/2 + jitter)
+	}
+	if earliest := now.Add(stapleMinRefreshDelay); refreshAt.Before(earliest) {
+		refreshAt = earliest
+	}
+	return refreshAt
+}
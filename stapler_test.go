@@ -0,0 +1,218 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type refreshResult struct {
+	leaf    *x509.Certificate
+	details *ResponseDetails
+	err     error
+}
+
+// Return a Stapler which reports each refresh on the returned channel
+func newTestStapler(t *testing.T) (*Stapler, <-chan refreshResult) {
+	refreshes := make(chan refreshResult, 16)
+	stapler := &Stapler{
+		OnRefresh: func(leaf *x509.Certificate, details *ResponseDetails, err error) {
+			refreshes <- refreshResult{leaf, details, err}
+		},
+	}
+	t.Cleanup(stapler.Close)
+	return stapler, refreshes
+}
+
+func waitForRefresh(t *testing.T, refreshes <-chan refreshResult) refreshResult {
+	t.Helper()
+	select {
+	case result := <-refreshes:
+		return result
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the staple to be refreshed")
+		return refreshResult{}
+	}
+}
+
+func TestStaplerRefresh(t *testing.T) {
+	responder := newTestResponder(t)
+	leaf, cert := issueTestCertificate(t, responder)
+	stapler, refreshes := newTestStapler(t)
+
+	if err := stapler.Add(cert); err != nil {
+		t.Fatal(err)
+	}
+	result := waitForRefresh(t, refreshes)
+	if result.err != nil {
+		t.Fatalf("refresh failed: %s", result.err)
+	}
+	if !bytes.Equal(result.leaf.Raw, leaf.Raw) {
+		t.Errorf("OnRefresh called with the wrong leaf")
+	}
+	if result.details == nil || result.details.Revoked() {
+		t.Errorf("OnRefresh called with details %v; want a good status", result.details)
+	}
+
+	staple := stapler.Staple(cert)
+	if staple == nil {
+		t.Fatal("Staple returned nil after a successful refresh")
+	}
+	served, err := stapler.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served.OCSPStaple, staple) {
+		t.Errorf("GetCertificate didn't include the current staple")
+	}
+	if cert.OCSPStaple != nil {
+		t.Errorf("GetCertificate modified the added certificate")
+	}
+}
+
+func TestStaplerRefreshError(t *testing.T) {
+	responder := newTestResponder(t)
+	responder.SetHTTPError(http.StatusInternalServerError)
+	_, cert := issueTestCertificate(t, responder)
+	stapler, refreshes := newTestStapler(t)
+
+	if err := stapler.Add(cert); err != nil {
+		t.Fatal(err)
+	}
+	if result := waitForRefresh(t, refreshes); result.err == nil {
+		t.Fatal("refresh succeeded despite HTTP error")
+	}
+	if staple := stapler.Staple(cert); staple != nil {
+		t.Errorf("Staple returned a staple after a failed refresh")
+	}
+}
+
+func TestStaplerAddTwice(t *testing.T) {
+	responder := newTestResponder(t)
+	_, cert := issueTestCertificate(t, responder)
+	stapler, refreshes := newTestStapler(t)
+
+	if err := stapler.Add(cert); err != nil {
+		t.Fatal(err)
+	}
+	waitForRefresh(t, refreshes)
+
+	// A different tls.Certificate value with the same leaf replaces the first
+	// without starting another refresh goroutine
+	again := *cert
+	if err := stapler.Add(&again); err != nil {
+		t.Fatal(err)
+	}
+	stapler.mu.Lock()
+	count := len(stapler.staples)
+	current := stapler.staples[0].cert
+	stapler.mu.Unlock()
+	if count != 1 {
+		t.Fatalf("Stapler has %d staples; want 1", count)
+	}
+	if current != &again {
+		t.Errorf("Add didn't replace the previously-added certificate")
+	}
+	if stapler.Staple(cert) == nil {
+		t.Errorf("Add discarded the existing staple")
+	}
+	select {
+	case <-refreshes:
+		t.Errorf("Add started another refresh")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestStaplerClosed(t *testing.T) {
+	responder := newTestResponder(t)
+	_, cert := issueTestCertificate(t, responder)
+	stapler, _ := newTestStapler(t)
+
+	stapler.Close()
+	if err := stapler.Add(cert); err != ErrStaplerClosed {
+		t.Errorf("Add after Close returned %v; want ErrStaplerClosed", err)
+	}
+}
+func TestStaplerPrewarmRemove(t *testing.T) {
+	responder := newTestResponder(t)
+	_, oldCert := issueTestCertificate(t, responder)
+	_, newCert := issueTestCertificate(t, responder)
+	stapler, refreshes := newTestStapler(t)
+
+	if err := stapler.Add(oldCert); err != nil {
+		t.Fatal(err)
+	}
+	if err := stapler.Prewarm(newCert); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if result := waitForRefresh(t, refreshes); result.err != nil {
+			t.Fatalf("refresh failed: %s", result.err)
+		}
+	}
+
+	// A prewarmed certificate has a staple, but isn't served until it's added
+	if stapler.Staple(newCert) == nil {
+		t.Errorf("prewarmed certificate has no staple")
+	}
+	served, err := stapler.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served.Certificate[0], oldCert.Certificate[0]) {
+		t.Errorf("GetCertificate served the prewarmed certificate")
+	}
+
+	// The staple is found even though Add is passed a different tls.Certificate value
+	deployed := *newCert
+	if err := stapler.Add(&deployed); err != nil {
+		t.Fatal(err)
+	}
+	stapler.Remove(oldCert)
+	if stapler.Staple(oldCert) != nil {
+		t.Errorf("removed certificate still has a staple")
+	}
+	served, err = stapler.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served.Certificate[0], newCert.Certificate[0]) {
+		t.Errorf("GetCertificate didn't serve the added certificate")
+	}
+	if served.OCSPStaple == nil {
+		t.Errorf("GetCertificate didn't include the prewarmed staple")
+	}
+
+	stapler.Remove(&deployed)
+	if _, err := stapler.GetCertificate(&tls.ClientHelloInfo{}); err == nil {
+		t.Errorf("GetCertificate succeeded after every certificate was removed")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The number of responders listed in [ScanReport].SlowestResponders
+const slowestRespondersCount = 10
+
+// Accumulates [Evaluation]s into a summary of a scan, so that users get an
+// overview of the results without post-processing them.  Pass it in
+// [BatchOptions].Summary to summarize every evaluation performed by
+// [EvaluateBatch], or call Add with each evaluation.
+//
+// The zero value is ready to use.  A ScanSummary is safe for concurrent use.
+type ScanSummary struct {
+	mu          sync.Mutex
+	start       time.Time
+	last        time.Time
+	evaluations int
+	byStatus    map[string]int
+	byErrorCode map[ErrorCode]int
+	findings    map[string]int
+	responders  map[string]*responderTimes
+}
+
+type responderTimes struct {
+	queries int
+	total   time.Duration
+	max     time.Duration
+}
+
+// The response times of one responder, as listed in a [ScanReport]
+type ResponderLatency struct {
+	ResponderURL string        `json:"responder_url"`
+	Queries      int           `json:"queries"`
+	Mean         time.Duration `json:"mean"`
+	Max          time.Duration `json:"max"`
+}
+
+// A summary of the evaluations in a [ScanSummary], suitable for serializing as JSON
+type ScanReport struct {
+	Evaluations int `json:"evaluations"`
+
+	// The time from the start of the scan (or the first evaluation, if the
+	// ScanSummary wasn't passed to [EvaluateBatch]) to the last evaluation
+	Duration time.Duration `json:"duration"`
+
+	ByStatus    map[string]int    `json:"by_status"`     // Keyed by "good", "revoked", or "unknown", for evaluations with a verified response
+	ByErrorCode map[ErrorCode]int `json:"by_error_code"` // Keyed by [ErrorCodeOf], for evaluations which failed
+	Findings    map[string]int    `json:"findings"`      // Keyed by [Finding].Lint
+
+	// The responders with the highest mean response time, slowest first, up to 10.
+	// Only evaluations which queried the responder are included.
+	SlowestResponders []ResponderLatency `json:"slowest_responders"`
+}
+
+// Record the start of the scan, unless an evaluation has already been added
+func (summary *ScanSummary) begin() {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+	if summary.start.IsZero() {
+		summary.start = time.Now()
+	}
+}
+
+// Add an evaluation to the summary
+func (summary *ScanSummary) Add(eval Evaluation) {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+
+	now := time.Now()
+	if summary.start.IsZero() {
+		summary.start = now
+	}
+	summary.last = now
+	summary.evaluations++
+
+	if eval.Response != nil && (eval.Err == nil || ErrorCodeOf(eval.Err) == ErrorCodeUnknown) {
+		if summary.byStatus == nil {
+			summary.byStatus = make(map[string]int)
+		}
+		summary.byStatus[statusName(eval.Response.Status)]++
+	}
+	if eval.Err != nil {
+		if summary.byErrorCode == nil {
+			summary.byErrorCode = make(map[ErrorCode]int)
+		}
+		summary.byErrorCode[ErrorCodeOf(eval.Err)]++
+	}
+	for _, finding := range eval.Findings {
+		if summary.findings == nil {
+			summary.findings = make(map[string]int)
+		}
+		summary.findings[finding.Lint]++
+	}
+	if eval.ResponderURL != nil && eval.Method != nil {
+		if summary.responders == nil {
+			summary.responders = make(map[string]*responderTimes)
+		}
+		times := summary.responders[*eval.ResponderURL]
+		if times == nil {
+			times = new(responderTimes)
+			summary.responders[*eval.ResponderURL] = times
+		}
+		times.queries++
+		times.total += eval.ResponseTime
+		if eval.ResponseTime > times.max {
+			times.max = eval.ResponseTime
+		}
+	}
+}
+
+// Return a report of the evaluations added so far
+func (summary *ScanSummary) Report() ScanReport {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+
+	report := ScanReport{
+		Evaluations: summary.evaluations,
+		ByStatus:    make(map[string]int),
+		ByErrorCode: make(map[ErrorCode]int),
+		Findings:    make(map[string]int),
+	}
+	if !summary.last.IsZero() {
+		report.Duration = summary.last.Sub(summary.start)
+	}
+	for status, count := range summary.byStatus {
+		report.ByStatus[status] = count
+	}
+	for code, count := range summary.byErrorCode {
+		report.ByErrorCode[code] = count
+	}
+	for lint, count := range summary.findings {
+		report.Findings[lint] = count
+	}
+
+	for url, times := range summary.responders {
+		report.SlowestResponders = append(report.SlowestResponders, ResponderLatency{
+			ResponderURL: url,
+			Queries:      times.queries,
+			Mean:         times.total / time.Duration(times.queries),
+			Max:          times.max,
+		})
+	}
+	sort.Slice(report.SlowestResponders, func(i, j int) bool {
+		a, b := report.SlowestResponders[i], report.SlowestResponders[j]
+		if a.Mean != b.Mean {
+			return a.Mean > b.Mean
+		}
+		return a.ResponderURL < b.ResponderURL
+	})
+	if len(report.SlowestResponders) > slowestRespondersCount {
+		report.SlowestResponders = report.SlowestResponders[:slowestRespondersCount]
+	}
+	return report
+}
+
+// Return a human-readable rendition of the report
+func (report *ScanReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d evaluations in %s\n", report.Evaluations, report.Duration.Round(time.Millisecond))
+	writeCounts(&b, "Status", report.ByStatus)
+	errorCounts := make(map[string]int, len(report.ByErrorCode))
+	for code, count := range report.ByErrorCode {
+		errorCounts[string(code)] = count
+	}
+	writeCounts(&b, "Errors", errorCounts)
+	writeCounts(&b, "Findings", report.Findings)
+	if len(report.SlowestResponders) > 0 {
+		fmt.Fprintf(&b, "Slowest responders:\n")
+		for _, responder := range report.SlowestResponders {
+			fmt.Fprintf(&b, "  %-40s mean %s, max %s (%d queries)\n", responder.ResponderURL, responder.Mean.Round(time.Millisecond), responder.Max.Round(time.Millisecond), responder.Queries)
+		}
+	}
+	return b.String()
+}
+
+// Write a heading followed by the counts, largest first
+func writeCounts(b *strings.Builder, heading string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	fmt.Fprintf(b, "%s:\n", heading)
+	for _, key := range keys {
+		fmt.Fprintf(b, "  %-40s %d\n", key, counts[key])
+	}
+}
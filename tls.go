@@ -0,0 +1,96 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// The TLS parameters negotiated with an "https://" OCSP responder
+type TLSInfo struct {
+	Version     uint16 // Such as [tls.VersionTLS13]
+	CipherSuite uint16 // Such as [tls.TLS_AES_128_GCM_SHA256]
+}
+
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+	return &TLSInfo{Version: state.Version, CipherSuite: state.CipherSuite}
+}
+
+// Return the name of the TLS version, such as "TLS 1.3"
+func (info *TLSInfo) VersionName() string {
+	switch info.Version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", info.Version)
+	}
+}
+
+// Return the name of the cipher suite, such as "TLS_AES_128_GCM_SHA256"
+func (info *TLSInfo) CipherSuiteName() string {
+	return tls.CipherSuiteName(info.CipherSuite)
+}
+
+// Return a description of why the negotiated TLS parameters are deprecated,
+// or the empty string if they aren't.  TLS versions before 1.2 are deprecated
+// by RFC 8996, and cipher suites are deprecated if [tls.InsecureCipherSuites]
+// lists them.  Relying parties with legacy TLS stacks may be unable to reach a
+// responder which requires newer parameters, but Go's default client refuses to
+// negotiate deprecated parameters, so they are only observed if [Config].HTTPClient
+// permits them.
+func (info *TLSInfo) Deprecation() string {
+	if info.Version < tls.VersionTLS12 {
+		return info.VersionName() + " is deprecated by RFC 8996"
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == info.CipherSuite {
+			return "cipher suite " + suite.Name + " is insecure"
+		}
+	}
+	return ""
+}
+
+// Return a finding if the TLS parameters are deprecated, or nil otherwise
+func tlsFinding(info *TLSInfo) *Finding {
+	if info == nil {
+		return nil
+	}
+	if deprecation := info.Deprecation(); deprecation != "" {
+		finding := lintResponderTLSDeprecated.finding("Responder negotiated deprecated TLS parameters: " + deprecation)
+		return &finding
+	}
+	return nil
+}
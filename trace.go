@@ -0,0 +1,146 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// A breakdown of the time spent on an HTTP request to an OCSP responder.
+// Phases which did not occur (e.g. DNS and Connect when an idle connection
+// was reused, or TLSHandshake for an http:// responder) are zero.
+type Timings struct {
+	DNS          time.Duration // Resolving the responder's hostname
+	Connect      time.Duration // Establishing the TCP connection
+	TLSHandshake time.Duration // Performing the TLS handshake (https:// responders only)
+	FirstByte    time.Duration // From finishing writing the request to receiving the first byte of the response
+	BodyRead     time.Duration // Reading the response body, after the headers were received
+}
+
+// Information about an HTTP request to an OCSP responder, as passed to [Config].OnQueryDone
+type QueryInfo struct {
+	ResponderURL string
+	Method       string   // "GET" or "POST"
+	RemoteAddr   string   // The IP address and port actually contacted, or empty if no connection was made
+	StatusCode   int      // The HTTP status code, or zero if no HTTP response was received
+	TLS          *TLSInfo // The negotiated TLS parameters, or nil if the responder isn't https
+	Timings      Timings
+	Err          error // Non-nil if the request failed or the response was rejected
+
+	// The domain names of the certificate being checked, as labeled by
+	// [Config].DomainLabels, or nil if DomainLabels is nil
+	DomainNames []string
+}
+
+// Records the timings of an HTTP request using net/http/httptrace.  Hooks may
+// be called concurrently (e.g. when dialing several addresses at once), so
+// every field is protected by mu.
+type queryTrace struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	gotHeaders   time.Time
+	remoteAddr   string
+	timings      Timings
+}
+
+func (trace *queryTrace) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.timings.DNS = time.Since(trace.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			if trace.connectStart.IsZero() {
+				trace.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			if err == nil {
+				trace.timings.Connect = time.Since(trace.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.timings.TLSHandshake = time.Since(trace.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.remoteAddr = info.Conn.RemoteAddr().String()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.wroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.timings.FirstByte = time.Since(trace.wroteRequest)
+		},
+	})
+}
+
+func (trace *queryTrace) gotResponse() {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.gotHeaders = time.Now()
+}
+
+func (trace *queryTrace) readBody() {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.timings.BodyRead = time.Since(trace.gotHeaders)
+}
+
+func (trace *queryTrace) info() (string, Timings) {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	return trace.remoteAddr, trace.timings
+}
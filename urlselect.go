@@ -0,0 +1,132 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+//
+// Except as contained in this notice, the name(s) of the above copyright
+// holders shall not be used in advertising or otherwise to promote the
+// sale, use or other dealings in this Software without prior written
+// authorization.
+
+package ocsputil
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Specifies how a [URLSelector] chooses among a certificate's OCSP responder URLs
+type URLStrategy int
+
+const (
+	// Use the first URL
+	URLFirst URLStrategy = iota
+
+	// Use a URL chosen uniformly at random
+	URLRandom
+
+	// Use each URL in turn
+	URLRoundRobin
+
+	// Use the URL with the lowest latency observed so far, after trying each
+	// URL once.  Failed queries count as taking [QueryTimeout].
+	URLLowestLatency
+)
+
+// The weight given to each new latency sample by [URLLowestLatency]
+const urlLatencyWeight = 0.2
+
+// Chooses which OCSP responder URL to query when a certificate contains several
+// (for example, to spread load across a CA's responders, or to prefer the one
+// closest to the vantage point).  Only "http://" URLs are considered, unless
+// there are none and [Config].AllowHTTPS is true.  Certificates with a single
+// URL and [IssuerOverride].ResponderURL are unaffected.
+//
+// The zero value is ready to use, and uses [URLFirst].  A URLSelector is safe
+// for concurrent use.
+type URLSelector struct {
+	Strategy URLStrategy
+
+	mu      sync.Mutex
+	next    map[string]int           // round-robin position, keyed by the joined list of URLs
+	latency map[string]time.Duration // moving average latency, keyed by URL
+}
+
+// Return the URL to use from urls, which must be non-empty, and the reason
+// it was chosen
+func (selector *URLSelector) choose(urls []string) (string, string) {
+	if len(urls) == 1 {
+		return urls[0], "only URL"
+	}
+	strategy := URLFirst
+	if selector != nil {
+		strategy = selector.Strategy
+	}
+	switch strategy {
+	case URLRandom:
+		return urls[rand.Intn(len(urls))], "random"
+	case URLRoundRobin:
+		selector.mu.Lock()
+		defer selector.mu.Unlock()
+		if selector.next == nil {
+			selector.next = make(map[string]int)
+		}
+		key := strings.Join(urls, " ")
+		i := selector.next[key] % len(urls)
+		selector.next[key] = i + 1
+		return urls[i], "round robin"
+	case URLLowestLatency:
+		selector.mu.Lock()
+		defer selector.mu.Unlock()
+		best := -1
+		for i, url := range urls {
+			latency, ok := selector.latency[url]
+			if !ok {
+				return url, "not yet measured"
+			}
+			if best == -1 || latency < selector.latency[urls[best]] {
+				best = i
+			}
+		}
+		return urls[best], "lowest latency"
+	default:
+		return urls[0], "first URL"
+	}
+}
+
+// Record the latency of a query to url, or its failure
+func (selector *URLSelector) observe(url string, latency time.Duration, failed bool) {
+	if selector.Strategy != URLLowestLatency {
+		return
+	}
+	if failed && latency < QueryTimeout {
+		latency = QueryTimeout
+	}
+	selector.mu.Lock()
+	defer selector.mu.Unlock()
+	if selector.latency == nil {
+		selector.latency = make(map[string]time.Duration)
+	}
+	if average, ok := selector.latency[url]; ok {
+		selector.latency[url] = average + time.Duration(urlLatencyWeight*float64(latency-average))
+	} else {
+		selector.latency[url] = latency
+	}
+}